@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/components"
+)
+
+const chartAPIPrefix = "/api/chart/"
+
+// chartData is the JSON form of a single chart's series.
+type chartData struct {
+	Page   string        `json:"page"`
+	Chart  string        `json:"chart"`
+	XAxis  []interface{} `json:"xAxis,omitempty"`
+	Series []seriesData  `json:"series"`
+}
+
+type seriesData struct {
+	Name   string        `json:"name"`
+	Values []interface{} `json:"values"`
+}
+
+type apiError struct {
+	Error string   `json:"error"`
+	Valid []string `json:"valid,omitempty"`
+}
+
+// serveChartJSON answers /api/chart/{page}/{name}.json with the series of
+// the named chart on the named page. Node charts take the node as ?node=.
+func serveChartJSON(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, chartAPIPrefix)
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".json") {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "expected " + chartAPIPrefix + "{page}/{name}.json"})
+		return
+	}
+	pageName, chartName := parts[0], strings.TrimSuffix(parts[1], ".json")
+
+	var chart components.Charter
+	var valid []string
+	switch {
+	case contains(matrixFiles, pageName):
+		data, err := loadMatrixPage(pageName)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		for _, c := range matrixCharts {
+			valid = append(valid, c.name)
+			if c.name == chartName {
+				chart = c.build(data)
+			}
+		}
+		for _, c := range nodeCharts {
+			valid = append(valid, c.name)
+			if c.name != chartName {
+				continue
+			}
+			node := r.URL.Query().Get("node")
+			if _, ok := data.NodeMatrix[node]; !ok {
+				writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown node %q, give one with ?node=", node), Valid: nodeIDs(data)})
+				return
+			}
+			chart = c.build(node, data)
+		}
+	case contains(batteryMeasurementFiles, pageName):
+		data, err := loadBatteryMeasurements(logPath(pageName))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		for _, c := range batteryCharts {
+			valid = append(valid, c.name)
+			if c.name == chartName {
+				chart = c.build(data)
+			}
+		}
+	default:
+		pages := append(append([]string{}, matrixFiles...), batteryMeasurementFiles...)
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown page %q", pageName), Valid: pages})
+		return
+	}
+	if chart == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown chart %q", chartName), Valid: valid})
+		return
+	}
+
+	out, err := extractChartData(chart)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	out.Page, out.Chart = pageName, chartName
+	writeJSON(w, http.StatusOK, out)
+}
+
+// extractChartData pulls the axis labels and series values out of a built
+// chart through the same option JSON go-echarts renders into the page.
+func extractChartData(chart components.Charter) (*chartData, error) {
+	c, ok := chart.(interface{ JSON() map[string]interface{} })
+	if !ok {
+		return nil, fmt.Errorf("chart type %s has no series", chart.Type())
+	}
+	chart.Validate()
+	b, err := json.Marshal(c.JSON())
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		XAxis []struct {
+			Data []interface{} `json:"data"`
+		} `json:"xAxis"`
+		YAxis []struct {
+			Data []interface{} `json:"data"`
+		} `json:"yAxis"`
+		Series []struct {
+			Name string                   `json:"name"`
+			Data []map[string]interface{} `json:"data"`
+		} `json:"series"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	out := &chartData{Series: make([]seriesData, 0, len(raw.Series))}
+	if len(raw.XAxis) > 0 {
+		out.XAxis = raw.XAxis[0].Data
+	}
+	// Horizontal bar charts carry their categories on the y axis.
+	if out.XAxis == nil && len(raw.YAxis) > 0 {
+		out.XAxis = raw.YAxis[0].Data
+	}
+	for _, s := range raw.Series {
+		values := make([]interface{}, 0, len(s.Data))
+		for _, d := range s.Data {
+			values = append(values, d["value"])
+		}
+		out.Series = append(out.Series, seriesData{Name: s.Name, Values: values})
+	}
+	return out, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"os/exec"
+	"runtime"
+)
+
+// openBrowser opens url in the default browser without waiting for it. A
+// missing launcher is logged rather than treated as an error, since the
+// dashboard works just as well opened by hand.
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		slog.Warn("can't open a browser", "url", url, "err", err)
+		return
+	}
+	go cmd.Wait()
+}
+
+// browseURL is the URL of the index page served on addr, over HTTPS when
+// secure is set. A listener on every interface is browsed on localhost.
+func browseURL(addr net.Addr, secure bool) string {
+	scheme := "http://"
+	if secure {
+		scheme = "https://"
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return scheme + addr.String() + "/"
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return scheme + net.JoinHostPort(host, port) + "/"
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+// completeness describes how many records made it into a chart out of those
+// available, e.g. "142/200 samples with valid timestamps".
+func completeness(used, available int, what string) string {
+	return fmt.Sprintf("%d/%d %s", used, available, what)
+}
+
+// addCaption appends a line to the chart subtitle, keeping any subtitle the
+// chart already has.
+func addCaption(title *opts.Title, caption string) {
+	if title.Subtitle != "" {
+		title.Subtitle += "\n"
+	}
+	title.Subtitle += caption
+}
+
+// withAreaFill shades the area under a line series as configured by
+// -area-fill and -area-opacity.
+func withAreaFill() charts.SeriesOpts {
+	return func(s *charts.SingleSeries) {
+		if cfg.AreaFill {
+			s.AreaStyle = &opts.AreaStyle{Opacity: float32(cfg.AreaOpacity)}
+		}
+	}
+}
+
+// valueAxisType returns the echarts type of a numeric axis, logarithmic or
+// the default linear one.
+func valueAxisType(logScale bool) string {
+	if logScale {
+		return "log"
+	}
+	return ""
+}
+
+// tooltip returns the tooltip options for a chart of the given kind (one of
+// the types.Chart* names), triggered as configured for that kind.
+func tooltip(kind string) opts.Tooltip {
+	return opts.Tooltip{Show: true, Trigger: cfg.TooltipTriggers[kind]}
+}
+
+// enoughSamples reports whether n samples meet the minimum configured for a
+// statistical chart of the given kind. When they don't, the chart's subtitle
+// is replaced with a placeholder and the caller should leave it empty rather
+// than plot a misleading result.
+func enoughSamples(title *opts.Title, kind string, n int) bool {
+	min := cfg.MinSamples[kind]
+	if n >= min {
+		return true
+	}
+	title.Subtitle = fmt.Sprintf("not enough data (n=%d < %d)", n, min)
+	return false
+}
+
+// withPercentileLines draws a labelled horizontal line at each of the
+// -percentiles of values.
+func withPercentileLines(values []float64) charts.SeriesOpts {
+	return func(s *charts.SingleSeries) {
+		if len(cfg.Percentiles) == 0 || len(values) == 0 {
+			return
+		}
+		if s.MarkLines == nil {
+			s.MarkLines = &opts.MarkLines{}
+		}
+		for _, p := range cfg.Percentiles {
+			s.MarkLines.Data = append(s.MarkLines.Data, opts.MarkLineNameYAxisItem{
+				Name:  fmt.Sprintf("p%g", p),
+				YAxis: math.Round(percentile(values, p)*10) / 10,
+			})
+		}
+		s.MarkLines.Label = &opts.Label{Show: true, Formatter: "{b}: {c}"}
+	}
+}
+
+// withAnnotations marks the mean of a line series with a horizontal line and
+// its maximum with a pin, unless -annotations is off.
+func withAnnotations() charts.SeriesOpts {
+	return func(s *charts.SingleSeries) {
+		if !cfg.Annotations {
+			return
+		}
+		charts.WithMarkLineNameTypeItemOpts(opts.MarkLineNameTypeItem{Name: "Mean", Type: "average"})(s)
+		charts.WithMarkPointNameTypeItemOpts(opts.MarkPointNameTypeItem{Name: "Max", Type: "max"})(s)
+		s.MarkLines.Label = &opts.Label{Show: true, Formatter: "{b}: {c}"}
+	}
+}
+
+// thinLine cuts the points of a line series down to -max-points, chosen by
+// lttb over values, the value each item plots. Kept points carry their
+// original index as x so they stay in place on a count axis. Series within
+// the limit are returned as they are.
+func thinLine(items []opts.LineData, values []float64) []opts.LineData {
+	if cfg.MaxPoints == 0 || len(items) <= cfg.MaxPoints {
+		return items
+	}
+	kept := lttb(values, cfg.MaxPoints)
+	thinned := make([]opts.LineData, 0, len(kept))
+	for _, i := range kept {
+		thinned = append(thinned, opts.LineData{Value: []interface{}{i, items[i].Value}})
+	}
+	return thinned
+}
+
+// withStack stacks a series onto the others sharing the same stack name.
+// charts.WithBarChartOpts would also reset the series type, so set it
+// directly.
+func withStack(stack string) charts.SeriesOpts {
+	return func(s *charts.SingleSeries) {
+		s.Stack = stack
+	}
+}
+
+// baseOf returns the options shared by every kind of chart this package
+// builds, or nil for a kind of chart it doesn't build.
+func baseOf(c components.Charter) *charts.BaseConfiguration {
+	switch c := c.(type) {
+	case *charts.Line:
+		return &c.BaseConfiguration
+	case *charts.Bar:
+		return &c.BaseConfiguration
+	case *charts.Pie:
+		return &c.BaseConfiguration
+	case *charts.Parallel:
+		return &c.BaseConfiguration
+	case *charts.Gauge:
+		return &c.BaseConfiguration
+	case *charts.Scatter:
+		return &c.BaseConfiguration
+	case *charts.BoxPlot:
+		return &c.BaseConfiguration
+	case *charts.HeatMap:
+		return &c.BaseConfiguration
+	}
+	return nil
+}
+
+// newRand returns a source of randomness seeded with -seed. Each page
+// render takes a fresh one so its output doesn't depend on what rendered
+// before it.
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(cfg.Seed))
+}
+
+// setChartID replaces the chart's random ID, which go-echarts draws from
+// the unseeded global source, with one drawn from rng. JS functions the
+// builder added are updated to refer to the new ID.
+func setChartID(c components.Charter, rng *rand.Rand) {
+	base := baseOf(c)
+	if base == nil {
+		return
+	}
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	id := make([]byte, 12)
+	for i := range id {
+		id[i] = letters[rng.Intn(len(letters))]
+	}
+	for i, fn := range base.JSFunctions.Fns {
+		base.JSFunctions.Fns[i] = strings.ReplaceAll(fn, "goecharts_"+base.ChartID, "goecharts_"+string(id))
+	}
+	base.ChartID = string(id)
+}
+
+// themes are the go-echarts themes -theme accepts.
+var themes = []string{
+	"white", "dark",
+	types.ThemeChalk, types.ThemeEssos, types.ThemeInfographic, types.ThemeMacarons,
+	types.ThemePurplePassion, types.ThemeRoma, types.ThemeRomantic, types.ThemeShine,
+	types.ThemeVintage, types.ThemeWalden, types.ThemeWesteros, types.ThemeWonderland,
+}
+
+// setTheme renders c in cfg.Theme, loading the theme's script when it isn't
+// built into echarts.
+func setTheme(c components.Charter) {
+	base := baseOf(c)
+	if base == nil {
+		return
+	}
+	base.Theme = cfg.Theme
+	if cfg.Theme != "white" && cfg.Theme != "dark" {
+		base.JSAssets.Add("themes/" + cfg.Theme + ".js")
+	}
+}
+
+// setSize sizes c as -chart-width by -chart-height.
+func setSize(c components.Charter) {
+	if base := baseOf(c); base != nil {
+		base.Initialization.Width, base.Initialization.Height = cfg.ChartWidth, cfg.ChartHeight
+	}
+}
+
+// noDataCaption heads the subtitle of a chart with nothing to plot.
+const noDataCaption = "no data"
+
+// markEmpty heads the subtitle of a chart that has no values to plot with
+// noDataCaption, so the empty box reads as missing data rather than a broken
+// chart. Missing values and, in a pie, zero slices don't count.
+func markEmpty(c components.Charter) {
+	base := baseOf(c)
+	if base == nil {
+		return
+	}
+	data, err := extractChartData(c)
+	if err != nil {
+		return
+	}
+	for _, s := range data.Series {
+		for _, v := range s.Values {
+			if v == nil || v == "-" || (c.Type() == types.ChartPie && v == 0.0) {
+				continue
+			}
+			return
+		}
+	}
+	if base.Title.Subtitle == "" {
+		base.Title.Subtitle = noDataCaption
+		return
+	}
+	base.Title.Subtitle = noDataCaption + "\n" + base.Title.Subtitle
+}
+
+// hideSeries deselects the legend entries -hidden-series or the -config
+// file lists for the named chart so those series start hidden.
+func hideSeries(name string, c components.Charter) {
+	hidden := cfg.HiddenSeries[name]
+	base := baseOf(c)
+	if len(hidden) == 0 || base == nil {
+		return
+	}
+	legend := &base.Legend
+	if legend.Selected == nil {
+		legend.Selected = map[string]bool{}
+	}
+	for _, series := range hidden {
+		legend.Selected[series] = false
+	}
+}
+
+// colorSeries gives every series of c named in -series-colors or the
+// -config file its configured color, so a series keeps one color across
+// charts and pages whatever else they plot. Other series keep the theme's.
+func colorSeries(c components.Charter) {
+	base := baseOf(c)
+	if len(cfg.SeriesColors) == 0 || base == nil {
+		return
+	}
+	for i := range base.MultiSeries {
+		s := &base.MultiSeries[i]
+		color, ok := cfg.SeriesColors[s.Name]
+		if !ok {
+			continue
+		}
+		style := opts.ItemStyle{}
+		if s.ItemStyle != nil {
+			style = *s.ItemStyle
+		}
+		style.Color = color
+		s.ItemStyle = &style
+	}
+}
+
+// rssiFilter screens a chart's RSSI readings, dropping those outside
+// -rssi-min to -rssi-max, such as the 0 or positive dBm of a misbehaving
+// device, so they don't stretch the chart's axis.
+type rssiFilter struct {
+	chart   string
+	dropped int
+}
+
+// keep reports whether rssi should be plotted. A zero RSSI was never
+// recorded and isn't counted as dropped.
+func (f *rssiFilter) keep(rssi int) bool {
+	if rssi == 0 {
+		return false
+	}
+	if rssi < cfg.RSSIMin || rssi > cfg.RSSIMax {
+		f.dropped++
+		return false
+	}
+	return true
+}
+
+// report logs how many readings the chart dropped, if any.
+func (f *rssiFilter) report() {
+	if f.dropped > 0 {
+		slog.Debug("dropped implausible RSSI readings", "chart", f.chart, "dropped", f.dropped,
+			"min", cfg.RSSIMin, "max", cfg.RSSIMax)
+	}
+}
+
+// chartStats summarises the numeric values plotted in a chart, for title
+// and subtitle templates. Title and Subtitle are what the chart would show
+// without a template.
+type chartStats struct {
+	Title    string
+	Subtitle string
+	Count    int
+	Mean     float64
+	Min      float64
+	Max      float64
+	Median   float64
+}
+
+// statsOf computes the chartStats of a chart over every numeric value of
+// every series. Values are rounded to one decimal like the charts'.
+func statsOf(c components.Charter, title opts.Title) (chartStats, error) {
+	stats := chartStats{Title: title.Title, Subtitle: title.Subtitle}
+	data, err := extractChartData(c)
+	if err != nil {
+		return stats, err
+	}
+	values := []float64{}
+	for _, s := range data.Series {
+		for _, v := range s.Values {
+			if f, ok := v.(float64); ok {
+				values = append(values, f)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return stats, nil
+	}
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	stats.Count = len(values)
+	stats.Mean = round(sum / float64(len(values)))
+	stats.Min = round(values[0])
+	stats.Max = round(values[len(values)-1])
+	stats.Median = round(percentile(values, 50))
+	return stats, nil
+}
+
+// applyTitleTemplates replaces the title and subtitle of the named chart
+// with its -title-template and -subtitle-template, if any.
+func applyTitleTemplates(name string, c components.Charter) error {
+	title, subtitle := cfg.TitleTemplates[name], cfg.SubtitleTemplates[name]
+	base := baseOf(c)
+	if (title == nil && subtitle == nil) || base == nil {
+		return nil
+	}
+	stats, err := statsOf(c, base.Title)
+	if err != nil {
+		return err
+	}
+	execute := func(t *template.Template, dst *string) error {
+		if t == nil {
+			return nil
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, stats); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*dst = b.String()
+		return nil
+	}
+	if err := execute(title, &base.Title.Title); err != nil {
+		return err
+	}
+	return execute(subtitle, &base.Title.Subtitle)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// command is a subcommand of the CLI. Each command parses its own flags.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "render every page and serve the dashboard (default)", runServe},
+	{"render", "render every page and exit", runRender},
+	{"export", "write matrix records as NDJSON or battery measurements as CSV, or post metrics to Datadog", runExport},
+	{"validate", "check the logs for data-quality issues", runValidate},
+	{"migrate", "rewrite the logs in the current schema", runMigrate},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [command] [flags]\n\ncommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun '%s <command> -h' for the flags of a command\n", filepath.Base(os.Args[0]))
+}
+
+// newFlagSet returns the flag set of the named command with the flags every
+// command shares registered.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.BoolVar(&cfg.JSONErrors, "json-errors", cfg.JSONErrors, "report errors as JSON lines on stderr")
+	fs.Func("log-level", "least severe log lines shown: debug, info, warn or error (default info)", setLogLevel)
+	fs.StringVar(&cfg.LogsDir, "logs-dir", cfg.LogsDir, "directory the logs are read from")
+	fs.Func("matrix-pages", "comma separated matrix logs, by name or http(s) URL, to render instead of scanning -logs-dir", pageList(&cfg.MatrixPages))
+	fs.Func("battery-pages", "comma separated battery logs, by name or http(s) URL, to render instead of scanning -logs-dir", pageList(&cfg.BatteryPages))
+	fs.DurationVar(&cfg.HTTPTimeout, "http-timeout", cfg.HTTPTimeout, "time allowed to fetch a log given by URL, or for each post to Datadog")
+	fs.IntVar(&cfg.ReadRetries, "read-retries", cfg.ReadRetries, "times to re-read a log that looks cut off, in case it is still being written")
+	fs.DurationVar(&cfg.ReadRetryDelay, "read-retry-delay", cfg.ReadRetryDelay, "wait before the first re-read of a cut off log, doubling each time")
+	fs.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "JSON file declaring the pages to render, their logs, titles and charts")
+	fs.Func("merge", "name=file1,file2,... to merge matrix logs into one combined page; repeatable", cfg.setMerge)
+	fs.StringVar(&cfg.Only, "only", cfg.Only, "work on just the named page")
+	return fs
+}
+
+// setLogLevel parses a -log-level value and applies it to the default logger.
+func setLogLevel(value string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("unknown log level %q, want debug, info, warn or error", value)
+	}
+	slog.SetLogLoggerLevel(level)
+	return nil
+}
+
+// pageList returns a flag setter for a comma separated list of page names.
+// A log given by URL is named after its file and read from the URL.
+func pageList(pages *[]string) func(string) error {
+	return func(value string) error {
+		*pages = nil
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			if isURL(p) {
+				url := p
+				p = logPage(url)
+				if cfg.LogSources == nil {
+					cfg.LogSources = map[string]string{}
+				}
+				cfg.LogSources[p] = url
+			}
+			*pages = append(*pages, p)
+		}
+		return nil
+	}
+}
+
+// parseFlags parses args into fs and settles which pages there are to work
+// on: those the -config file declares, those listed on the command line, or
+// else those found by scanning -logs-dir, plus any pages merged with -merge.
+// -only then narrows them down to a single page. The flags are validated
+// first, so a bad value fails before any log is read.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.Parse(args)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	switch {
+	case cfg.ConfigFile != "":
+		if err := cfg.loadPageConfig(cfg.ConfigFile); err != nil {
+			return err
+		}
+	case cfg.MatrixPages != nil || cfg.BatteryPages != nil:
+		matrixFiles, batteryMeasurementFiles = cfg.MatrixPages, cfg.BatteryPages
+	default:
+		if err := scanLogs(cfg.LogsDir); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(cfg.Merges))
+	for name := range cfg.Merges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if contains(matrixFiles, name) || contains(batteryMeasurementFiles, name) {
+			return fmt.Errorf("-merge page %q has the same name as a log in %s", name, cfg.LogsDir)
+		}
+		matrixFiles = append(matrixFiles, name)
+	}
+	if cfg.Only == "" {
+		return nil
+	}
+	switch {
+	case contains(matrixFiles, cfg.Only):
+		matrixFiles, batteryMeasurementFiles = []string{cfg.Only}, nil
+	case contains(batteryMeasurementFiles, cfg.Only):
+		matrixFiles, batteryMeasurementFiles = nil, []string{cfg.Only}
+	default:
+		available := append(append([]string{}, matrixFiles...), batteryMeasurementFiles...)
+		sort.Strings(available)
+		return fmt.Errorf("-only %q matches no page, available pages are %s", cfg.Only, strings.Join(available, ", "))
+	}
+	return nil
+}
+
+// parseRenderFlags parses args against the rendering flags plus any extra
+// flags the command registers.
+func parseRenderFlags(name string, args []string, extra func(fs *flag.FlagSet)) error {
+	fs := newFlagSet(name)
+	cfg.bindRenderFlags(fs)
+	if extra != nil {
+		extra(fs)
+	}
+	return parseFlags(fs, args)
+}
+
+func runRender(args []string) error {
+	if err := parseRenderFlags("render", args, nil); err != nil {
+		return err
+	}
+	return renderAll()
+}
+
+// defaultAddr is where the dashboard is served unless -addr or $PORT say
+// otherwise.
+const defaultAddr = "localhost:8089"
+
+func runServe(args []string) error {
+	var addr, grpcAddr, tlsCert, tlsKey string
+	var watchLogs, open bool
+	var watchInterval time.Duration
+	err := parseRenderFlags("serve", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&addr, "addr", defaultAddr, "address to serve the dashboard on; $PORT, if set, overrides the default")
+		fs.StringVar(&tlsCert, "tls-cert", "", "certificate file to serve the dashboard over HTTPS with; needs -tls-key")
+		fs.StringVar(&tlsKey, "tls-key", "", "private key file of -tls-cert")
+		fs.StringVar(&grpcAddr, "grpc-addr", "", "also serve the gRPC metrics service on this address, e.g. localhost:8090")
+		fs.BoolVar(&watchLogs, "watch", false, "re-render a page whenever its log changes")
+		fs.DurationVar(&watchInterval, "watch-interval", time.Second, "how often -watch checks the logs for changes")
+		fs.BoolVar(&open, "open", false, "open the index page in the default browser once the server is up")
+	})
+	if err != nil {
+		return err
+	}
+	if (tlsCert == "") != (tlsKey == "") {
+		return fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+	if watchLogs && watchInterval <= 0 {
+		return fmt.Errorf("-watch-interval must be positive, got %s", watchInterval)
+	}
+	if port := os.Getenv("PORT"); port != "" && addr == defaultAddr {
+		addr = ":" + port
+	}
+	// Serve whatever did render; renderAll has reported the rest.
+	if err := renderAll(); err != nil {
+		reportError(err)
+	}
+
+	// Everything long-running hangs off ctx so a single SIGINT or SIGTERM
+	// stops it all.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The first server to fail stops the rest and is what runServe returns,
+	// so the exit status shows it; a signal stopping them all returns nil.
+	errc := make(chan error, 2)
+	fail := func(err error) {
+		errc <- err
+		stop()
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		slog.Info("shutting down")
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var listening func(net.Addr)
+		if open {
+			listening = func(a net.Addr) { openBrowser(browseURL(a, tlsCert != "")) }
+		}
+		if err := serve(ctx, addr, tlsCert, tlsKey, listening); err != nil {
+			fail(fmt.Errorf("server failed: %w", err))
+		}
+		stop()
+	}()
+	if watchLogs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watch(ctx, watchInterval)
+		}()
+	}
+	if grpcAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := serveGRPC(ctx, grpcAddr); err != nil {
+				fail(fmt.Errorf("gRPC server failed: %w", err))
+			}
+			stop()
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errc:
+		return err
+	default:
+		return nil
+	}
+}
+
+func runExport(args []string) error {
+	fs := newFlagSet("export")
+	out := fs.String("o", "-", "file to write the export to, - for stdout")
+	format := fs.String("format", "ndjson", "ndjson for every matrix record, battery-csv for the battery measurements")
+	ddKey := fs.String("datadog-api-key", "", "post the matrix metrics to Datadog with this API key instead of writing them out")
+	ddSite := fs.String("datadog-site", "datadoghq.com", "Datadog site to post to")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *ddKey != "" {
+		return exportDatadog(*ddKey, *ddSite)
+	}
+
+	export := exportNDJSON
+	switch *format {
+	case "ndjson":
+	case "battery-csv":
+		export = exportBatteryCSV
+	default:
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return export(w)
+}
+
+func runValidate(args []string) error {
+	fs := newFlagSet("validate")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	issues := 0
+	for _, pageName := range matrixFiles {
+		data, err := loadMatrixPage(pageName)
+		if err != nil {
+			return err
+		}
+		report := &qualityReport{Page: pageName}
+		checkMatrix(data, report)
+		for _, i := range report.Issues {
+			if cfg.JSONErrors {
+				field := "NodeMatrix." + i.Node
+				if i.Index >= 0 {
+					field += fmt.Sprintf(".ConnectionHistory[%d]", i.Index)
+				}
+				reportError(&fileError{File: logPath(pageName), Field: field, Message: i.Problem})
+				continue
+			}
+			if i.Index < 0 {
+				fmt.Printf("%s: node %s: %s\n", pageName, i.Node, i.Problem)
+				continue
+			}
+			fmt.Printf("%s: node %s connection %d: %s\n", pageName, i.Node, i.Index, i.Problem)
+		}
+		issues += len(report.Issues)
+	}
+	for _, pageName := range batteryMeasurementFiles {
+		if _, err := loadBatteryMeasurements(logPath(pageName)); err != nil {
+			return err
+		}
+	}
+	if issues > 0 {
+		return fmt.Errorf("%d data-quality issues found", issues)
+	}
+	return nil
+}
+
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate")
+	to := fs.String("to", "migrated", "directory the rewritten logs are written to")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*to, 0755); err != nil {
+		return err
+	}
+	write := func(pageName string, v interface{}) error {
+		b, err := json.MarshalIndent(v, "", "   ")
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(*to, pageName+".log"), b, 0644)
+	}
+	for _, pageName := range matrixFiles {
+		data, err := loadMatrixPage(pageName)
+		if err != nil {
+			return err
+		}
+		if err := write(pageName, data); err != nil {
+			return err
+		}
+	}
+	for _, pageName := range batteryMeasurementFiles {
+		data, err := loadBatteryMeasurements(logPath(pageName))
+		if err != nil {
+			return err
+		}
+		if err := write(pageName, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
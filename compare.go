@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/components"
+)
+
+// compareLog is a matrix log charted on the -compare page, labelled Name.
+type compareLog struct {
+	Name string
+	Path string
+}
+
+// comparePage is the name of the -compare page in the output directory.
+const comparePage = "compare"
+
+// setCompare parses a -compare value into c.Compare. Each log is a path,
+// labelled after its file, or label=path.
+func (c *config) setCompare(value string) error {
+	c.Compare = nil
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, path := splitPair(entry)
+		if path == "" {
+			name, path = logPage(name), name
+		}
+		c.Compare = append(c.Compare, compareLog{Name: name, Path: path})
+	}
+	if len(c.Compare) < 2 {
+		return fmt.Errorf("-compare needs at least two logs, e.g. a.log,b.log")
+	}
+	return nil
+}
+
+// renderComparePage writes the compareCharts of the -compare logs, one
+// series per log, to compare.html.
+func renderComparePage(logs []compareLog) error {
+	sets := make([]dataset, 0, len(logs))
+	for _, l := range logs {
+		data, err := loadMatrix(l.Path)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, dataset{Name: l.Name, Data: data})
+	}
+	page := components.NewPage()
+	rng := newRand()
+	for _, c := range compareCharts {
+		chart := c.build(sets)
+		markEmpty(chart)
+		setChartID(chart, rng)
+		setTheme(chart)
+		setSize(chart)
+		hideSeries(c.name, chart)
+		colorSeries(chart)
+		if err := applyTitleTemplates(c.name, chart); err != nil {
+			return err
+		}
+		if err := snapshotChart(comparePage, c.name, chart); err != nil {
+			return err
+		}
+		page.AddCharts(chart)
+	}
+	page.PageTitle = comparePageTitle
+	f, err := os.Create(outPath(comparePage + ".html"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return renderPage(page, f, "", gridStyle()+tabsScript())
+}
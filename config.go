@@ -0,0 +1,698 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+// config holds the options that shape how pages are rendered and served.
+type config struct {
+	// LogsDir holds the logs pages are rendered from; OutDir is where the
+	// rendered pages are written and served from.
+	LogsDir string
+	OutDir  string
+
+	// MatrixPages and BatteryPages, when either is set, list the logs to
+	// render in place of every log found in LogsDir.
+	MatrixPages  []string
+	BatteryPages []string
+
+	// Only, when set, names the one page to work on; the others are left
+	// alone.
+	Only string
+
+	// ReadRetries is how many times a log that looks cut off is read again,
+	// in case it was caught mid-write, first waiting ReadRetryDelay and then
+	// twice as long each time.
+	ReadRetries    int
+	ReadRetryDelay time.Duration
+
+	// LogSources maps the pages whose log isn't LogsDir/<page>.log to the
+	// path or URL it is read from, as given by URL in -matrix-pages or
+	// -battery-pages or in the -config file. HTTPTimeout bounds fetching a
+	// log by URL and each post to Datadog.
+	LogSources  map[string]string
+	HTTPTimeout time.Duration
+
+	// ConfigFile, when set, is a JSON file declaring the pages to render in
+	// place of scanning LogsDir. PageTitles and PageCharts hold the titles
+	// and chart selections it gives, by page; a page missing from either
+	// keeps its default title or renders every chart.
+	ConfigFile string
+	PageTitles map[string]string
+	PageCharts map[string][]string
+
+	// Merges maps the name of a combined matrix page to the log files
+	// merged into it.
+	Merges map[string][]string
+
+	// Compare lists the matrix logs charted against each other on the
+	// compare page.
+	Compare []compareLog
+
+	// AreaFill shades the area under line charts at AreaOpacity.
+	AreaFill    bool
+	AreaOpacity float64
+
+	// RunsDir, when set, is a directory of dated matrix logs whose
+	// RunsMetric is trended on a cross-run page.
+	RunsDir    string
+	RunsMetric string
+
+	// DropNonMonotonic removes connections whose phase timestamps run
+	// backwards instead of only reporting them.
+	DropNonMonotonic bool
+
+	// SlowestN is how many content items the slowest downloads chart lists.
+	SlowestN int
+
+	// FlakiestN is how many nodes the flakiest nodes chart lists; nodes
+	// with fewer than FlakyMinAttempts connection attempts don't qualify.
+	FlakiestN        int
+	FlakyMinAttempts int
+
+	// SkipZeroSessions leaves nodes without a last successful connection
+	// duration out of the session duration chart instead of showing zero.
+	SkipZeroSessions bool
+
+	// EmbedRaw appends the parsed source log to each page for debugging.
+	EmbedRaw bool
+
+	// TooltipTriggers maps a chart kind (line, bar, pie, ...) to what
+	// triggers its tooltip: "axis", "item" or "none".
+	TooltipTriggers map[string]string
+
+	// HistogramBins is how many bins the download speed histogram has.
+	HistogramBins int
+
+	// MovingAverage is the window of the moving average drawn over the
+	// discovery delays; zero draws none.
+	MovingAverage int
+
+	// NodeFilter lists node ID prefixes; only nodes matching one are
+	// charted, or with ExcludeNodes only nodes matching none. An empty
+	// NodeFilter charts every node.
+	NodeFilter   []string
+	ExcludeNodes bool
+
+	// RSSIBinWidth is the width in dBm of the bins speed is averaged over.
+	RSSIBinWidth int
+
+	// RSSIMin and RSSIMax bound the plausible RSSI in dBm; the RSSI charts
+	// drop readings outside them, which would otherwise stretch the axes.
+	RSSIMin, RSSIMax int
+
+	// MinSamples maps a kind of statistical chart (boxplot, correlation,
+	// binned) to the fewest samples it will summarise. The
+	// boxplot minimum applies per node and is off by default.
+	MinSamples map[string]int
+
+	// BatteryStart is the charge, in percent, runtime projections start from.
+	BatteryStart float64
+
+	// BatteryHours is how long each battery measurement ran for.
+	BatteryHours float64
+
+	// Percentiles are marked as lines on the delay and speed charts.
+	Percentiles []float64
+
+	// Annotations marks the mean and maximum on the delay and download
+	// speed line charts.
+	Annotations bool
+
+	// Live adds a script to each page that updates its charts in place
+	// when the server re-renders them.
+	Live bool
+
+	// Theme is the go-echarts theme every chart is drawn in.
+	Theme string
+
+	// Format is "png" to also snapshot every chart of a page to a PNG file
+	// next to it, or "html" for just the pages.
+	Format string
+
+	// ChartWidth and ChartHeight size every chart, as CSS lengths.
+	ChartWidth  string
+	ChartHeight string
+
+	// Columns is how many charts sit side by side on wide screens.
+	Columns int
+
+	// Layout is "stack" to stack a page's charts one after another, or
+	// "tabs" to show one at a time behind a tab per chart.
+	Layout string
+
+	// Jobs is how many pages render at once.
+	Jobs int
+
+	// ScatterMaxPoints caps the points a scatter-like chart renders; denser
+	// data is thinned with subsampleGridIndices. Zero renders every point.
+	ScatterMaxPoints int
+
+	// MaxPoints caps the points of each series on the delay and download
+	// speed line charts; longer series are thinned with lttb. Zero plots
+	// every point.
+	MaxPoints int
+
+	// HiddenSeries maps a chart name to the series whose legend entries
+	// start deselected, hiding them until clicked, from -hidden-series and
+	// the -config file's hidden.
+	HiddenSeries map[string][]string
+
+	// SeriesColors maps a series name to the CSS color it is drawn in on
+	// every chart, from -series-colors and the -config file's colors.
+	SeriesColors map[string]string
+
+	// LogAxis lists the size charts whose value axis is logarithmic.
+	LogAxis map[string]bool
+
+	// Seed seeds every random choice made while rendering, chart IDs
+	// included, so the same seed renders the same HTML.
+	Seed int64
+
+	// JSONErrors reports errors as JSON lines on stderr for scripts to
+	// parse, instead of as log lines.
+	JSONErrors bool
+
+	// TitleTemplates and SubtitleTemplates map a chart name to a template
+	// for its title or subtitle, executed against the chart's chartStats.
+	TitleTemplates    map[string]*template.Template
+	SubtitleTemplates map[string]*template.Template
+}
+
+var cfg = config{
+	LogsDir:          "logs",
+	OutDir:           "html",
+	HTTPTimeout:      30 * time.Second,
+	ReadRetries:      3,
+	ReadRetryDelay:   200 * time.Millisecond,
+	AreaFill:         true,
+	AreaOpacity:      0.2,
+	Annotations:      true,
+	RunsMetric:       "speed",
+	SlowestN:         10,
+	FlakiestN:        10,
+	FlakyMinAttempts: 5,
+	HistogramBins:    10,
+	RSSIBinWidth:     10,
+	RSSIMin:          -100,
+	RSSIMax:          -20,
+	MovingAverage:    5,
+	BatteryStart:     100,
+	BatteryHours:     3,
+	Theme:            "white",
+	Format:           "html",
+	ChartWidth:       "900px",
+	ChartHeight:      "500px",
+	Columns:          1,
+	Layout:           "stack",
+	Jobs:             runtime.NumCPU(),
+	ScatterMaxPoints: 5000,
+	Seed:             1,
+	MinSamples: map[string]int{
+		"boxplot":     0,
+		"correlation": 10,
+		"binned":      10,
+	},
+	TooltipTriggers: map[string]string{
+		types.ChartLine:     "axis",
+		types.ChartBar:      "axis",
+		types.ChartScatter:  "item",
+		types.ChartBoxPlot:  "item",
+		types.ChartPie:      "item",
+		types.ChartParallel: "item",
+		types.ChartHeatMap:  "item",
+	},
+}
+
+// bindRenderFlags registers the command line flags that change how pages
+// are rendered.
+func (c *config) bindRenderFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.OutDir, "out-dir", c.OutDir, "directory the rendered pages are written to and served from")
+	fs.BoolVar(&c.AreaFill, "area-fill", c.AreaFill, "shade the area under line charts")
+	fs.Float64Var(&c.AreaOpacity, "area-opacity", c.AreaOpacity, "opacity of the line chart area fill, 0 to 1")
+	fs.StringVar(&c.RunsDir, "runs-dir", c.RunsDir, "directory of dated matrix logs to trend across runs")
+	fs.StringVar(&c.RunsMetric, "runs-metric", c.RunsMetric, "metric trended across runs: speed or success")
+	fs.Func("compare", "comma separated matrix logs, each path or label=path, to overlay on a comparison page", c.setCompare)
+	fs.BoolVar(&c.DropNonMonotonic, "drop-nonmonotonic", c.DropNonMonotonic, "drop connections whose timestamps are out of order")
+	fs.IntVar(&c.SlowestN, "slowest-n", c.SlowestN, "number of content items in the slowest downloads chart")
+	fs.IntVar(&c.FlakiestN, "flakiest-n", c.FlakiestN, "number of nodes in the flakiest nodes chart")
+	fs.IntVar(&c.FlakyMinAttempts, "flaky-min-attempts", c.FlakyMinAttempts, "connection attempts a node needs to appear in the flakiest nodes chart")
+	fs.BoolVar(&c.SkipZeroSessions, "skip-zero-sessions", c.SkipZeroSessions, "leave nodes with no last successful connection duration out of its chart")
+	fs.BoolVar(&c.EmbedRaw, "embed-raw", c.EmbedRaw, "append the parsed log JSON to the bottom of each page")
+	fs.IntVar(&c.HistogramBins, "histogram-bins", c.HistogramBins, "number of bins in the download speed histogram")
+	fs.IntVar(&c.MovingAverage, "moving-average", c.MovingAverage, "points in the moving average over the discovery delays, 0 for none")
+	fs.Func("filter", "comma separated node ID prefixes; only matching nodes are charted", c.setNodeFilter)
+	fs.BoolVar(&c.ExcludeNodes, "exclude", c.ExcludeNodes, "chart the nodes -filter doesn't match instead")
+	fs.IntVar(&c.RSSIBinWidth, "rssi-bin-width", c.RSSIBinWidth, "width in dBm of the RSSI bins link speed is averaged over")
+	fs.IntVar(&c.RSSIMin, "rssi-min", c.RSSIMin, "lowest plausible RSSI in dBm; the RSSI charts drop readings below it")
+	fs.IntVar(&c.RSSIMax, "rssi-max", c.RSSIMax, "highest plausible RSSI in dBm; the RSSI charts drop readings above it")
+	fs.Float64Var(&c.BatteryStart, "battery-start", c.BatteryStart, "starting battery percentage for the runtime projection")
+	fs.Float64Var(&c.BatteryHours, "battery-hours", c.BatteryHours, "hours each battery measurement ran for")
+	fs.BoolVar(&c.Live, "live", c.Live, "update charts in open pages when the server re-renders them")
+	fs.StringVar(&c.Theme, "theme", c.Theme, "chart theme: "+strings.Join(themes, ", "))
+	fs.StringVar(&c.Format, "format", c.Format, "html, or png to also write each chart as <page>/<chart>.png using headless Chrome")
+	fs.Func("chart-width", "width of each chart as a CSS length, e.g. 1200px or 90% (default 900px)", cssLengthFlag(&c.ChartWidth))
+	fs.Func("chart-height", "height of each chart as a CSS length, e.g. 800px or 40vh (default 500px)", cssLengthFlag(&c.ChartHeight))
+	fs.IntVar(&c.Columns, "columns", c.Columns, "number of chart columns on wide screens")
+	fs.StringVar(&c.Layout, "layout", c.Layout, "stack to stack the charts of a page, or tabs to show one chart per tab")
+	fs.IntVar(&c.Jobs, "jobs", c.Jobs, "number of pages rendered at once")
+	fs.IntVar(&c.ScatterMaxPoints, "scatter-max-points", c.ScatterMaxPoints, "thin the RSSI/speed points above this many, 0 to plot them all")
+	fs.IntVar(&c.MaxPoints, "max-points", c.MaxPoints, "thin delay and download speed series longer than this, 0 to plot every point")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "seed for chart IDs and any other random choice, so runs are reproducible")
+	fs.Func("tooltip-trigger", "comma separated kind=trigger overrides, e.g. line=item,bar=axis", c.setTooltipTriggers)
+	fs.BoolVar(&c.Annotations, "annotations", c.Annotations, "mark the mean and maximum on the delay and download speed line charts")
+	fs.Func("percentiles", "comma separated, ascending percentiles to mark on delay and speed charts, e.g. 50,90,95,99", c.setPercentiles)
+	fs.Func("hidden-series", "comma separated chart=series|series... to start deselected in the legend", c.setHiddenSeries)
+	fs.Func("series-colors", "comma separated series=color pairs drawing a series in the same CSS color on every chart, e.g. 'BLE to Wifi=#5470c6'", c.setSeriesColors)
+	fs.Func("log-axis", "comma separated size charts to plot on a logarithmic axis, e.g. content-size", c.setLogAxis)
+	fs.Func("title-template", "chart=template for a chart title, e.g. 'download-speed={{.Title}}, mean {{.Mean}} MBps'; repeatable", templateFlag(&c.TitleTemplates))
+	fs.Func("subtitle-template", "chart=template for a chart subtitle; repeatable", templateFlag(&c.SubtitleTemplates))
+	fs.Func("min-samples", "comma separated kind=n minimum samples for boxplot, correlation and binned charts", c.setMinSamples)
+}
+
+// validate reports option values that can't be used.
+func (c *config) validate() error {
+	if c.ReadRetries < 0 {
+		return fmt.Errorf("-read-retries must not be negative, got %d", c.ReadRetries)
+	}
+	if c.ReadRetryDelay < 0 {
+		return fmt.Errorf("-read-retry-delay must not be negative, got %s", c.ReadRetryDelay)
+	}
+	if c.RSSIBinWidth <= 0 {
+		return fmt.Errorf("-rssi-bin-width must be positive, got %d", c.RSSIBinWidth)
+	}
+	if c.RSSIMin >= c.RSSIMax {
+		return fmt.Errorf("-rssi-min must be below -rssi-max, got %d and %d", c.RSSIMin, c.RSSIMax)
+	}
+	if c.HistogramBins < 1 {
+		return fmt.Errorf("-histogram-bins must be at least 1, got %d", c.HistogramBins)
+	}
+	if !contains(themes, c.Theme) {
+		return fmt.Errorf("-theme must be one of %s, got %q", strings.Join(themes, ", "), c.Theme)
+	}
+	if c.MovingAverage < 0 {
+		return fmt.Errorf("-moving-average must not be negative, got %d", c.MovingAverage)
+	}
+	if c.SlowestN < 0 {
+		return fmt.Errorf("-slowest-n must not be negative, got %d", c.SlowestN)
+	}
+	if c.FlakiestN < 0 {
+		return fmt.Errorf("-flakiest-n must not be negative, got %d", c.FlakiestN)
+	}
+	if c.AreaOpacity < 0 || c.AreaOpacity > 1 {
+		return fmt.Errorf("-area-opacity must be between 0 and 1, got %g", c.AreaOpacity)
+	}
+	kinds := make([]string, 0, len(c.TooltipTriggers))
+	for kind := range c.TooltipTriggers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		if !contains(tooltipKinds, kind) {
+			return fmt.Errorf("-tooltip-trigger: unknown chart kind %q, valid kinds are %s", kind, strings.Join(tooltipKinds, ", "))
+		}
+	}
+	if c.Columns < 1 {
+		return fmt.Errorf("-columns must be at least 1, got %d", c.Columns)
+	}
+	if c.Layout != "stack" && c.Layout != "tabs" {
+		return fmt.Errorf("-layout must be stack or tabs, got %q", c.Layout)
+	}
+	if c.Layout == "tabs" && c.Columns > 1 {
+		return fmt.Errorf("-columns applies to -layout stack only")
+	}
+	if c.Format != "html" && c.Format != "png" {
+		return fmt.Errorf("-format must be html or png, got %q", c.Format)
+	}
+	if c.Format == "png" && (!strings.HasSuffix(c.ChartWidth, "px") || !strings.HasSuffix(c.ChartHeight, "px")) {
+		return fmt.Errorf("-format png needs -chart-width and -chart-height in px, got %s by %s", c.ChartWidth, c.ChartHeight)
+	}
+	if c.Jobs < 1 {
+		return fmt.Errorf("-jobs must be at least 1, got %d", c.Jobs)
+	}
+	if c.ScatterMaxPoints < 0 {
+		return fmt.Errorf("-scatter-max-points must not be negative, got %d", c.ScatterMaxPoints)
+	}
+	if c.BatteryHours <= 0 {
+		return fmt.Errorf("-battery-hours must be positive, got %g", c.BatteryHours)
+	}
+	if c.MaxPoints < 0 || c.MaxPoints == 1 {
+		return fmt.Errorf("-max-points must be 0 or at least 2, got %d", c.MaxPoints)
+	}
+	if c.BatteryStart <= 0 || c.BatteryStart > 100 {
+		return fmt.Errorf("-battery-start must be within (0, 100], got %g", c.BatteryStart)
+	}
+	return nil
+}
+
+// setNodeFilter parses a -filter value into c.NodeFilter.
+func (c *config) setNodeFilter(value string) error {
+	c.NodeFilter = nil
+	for _, prefix := range strings.Split(value, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			c.NodeFilter = append(c.NodeFilter, prefix)
+		}
+	}
+	return nil
+}
+
+// keepNode reports whether the node with id passes -filter and -exclude.
+func (c *config) keepNode(id string) bool {
+	if len(c.NodeFilter) == 0 {
+		return true
+	}
+	for _, prefix := range c.NodeFilter {
+		if strings.HasPrefix(id, prefix) {
+			return !c.ExcludeNodes
+		}
+	}
+	return c.ExcludeNodes
+}
+
+// logPath returns the path of the named page's log, or its URL if it is
+// read from one.
+func logPath(page string) string {
+	if src, ok := cfg.LogSources[page]; ok {
+		return src
+	}
+	return filepath.Join(cfg.LogsDir, page+".log")
+}
+
+// outPath returns the path of the named output file.
+func outPath(name string) string {
+	return filepath.Join(cfg.OutDir, name)
+}
+
+// setMerge parses a -merge value into c.Merges.
+func (c *config) setMerge(value string) error {
+	name, list := splitPair(value)
+	if name == "" {
+		return fmt.Errorf("-merge needs a page name, e.g. name=a.log,b.log")
+	}
+	var files []string
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("-merge %s lists no logs", name)
+	}
+	if c.Merges == nil {
+		c.Merges = map[string][]string{}
+	}
+	c.Merges[name] = files
+	return nil
+}
+
+// tooltipKinds are the kinds of chart this package builds, by the
+// types.Chart* names -tooltip-trigger takes.
+var tooltipKinds = []string{
+	types.ChartBar, types.ChartBoxPlot, types.ChartGauge, types.ChartHeatMap,
+	types.ChartLine, types.ChartParallel, types.ChartPie, types.ChartScatter,
+}
+
+// setTooltipTriggers parses a -tooltip-trigger value into c.TooltipTriggers.
+func (c *config) setTooltipTriggers(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		kind, trigger := splitPair(pair)
+		switch trigger {
+		case "axis", "item", "none":
+		default:
+			return fmt.Errorf("tooltip trigger for %q must be axis, item or none, got %q", kind, trigger)
+		}
+		c.TooltipTriggers[kind] = trigger
+	}
+	return nil
+}
+
+// setMinSamples parses a -min-samples value into c.MinSamples.
+func (c *config) setMinSamples(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		kind, n := splitPair(pair)
+		if _, ok := c.MinSamples[kind]; !ok {
+			return fmt.Errorf("unknown statistical chart kind %q", kind)
+		}
+		min, err := strconv.Atoi(n)
+		if err != nil || min < 0 {
+			return fmt.Errorf("minimum samples for %q must be a non-negative integer, got %q", kind, n)
+		}
+		c.MinSamples[kind] = min
+	}
+	return nil
+}
+
+// setHiddenSeries parses a -hidden-series value into c.HiddenSeries.
+func (c *config) setHiddenSeries(value string) error {
+	c.HiddenSeries = map[string][]string{}
+	for _, pair := range strings.Split(value, ",") {
+		chart, series := splitPair(pair)
+		if chart == "" {
+			continue
+		}
+		if !isChartName(chart) {
+			return fmt.Errorf("unknown chart %q in -hidden-series", chart)
+		}
+		for _, name := range strings.Split(series, "|") {
+			if name = strings.TrimSpace(name); name != "" {
+				c.HiddenSeries[chart] = append(c.HiddenSeries[chart], name)
+			}
+		}
+	}
+	return nil
+}
+
+// setSeriesColors parses a -series-colors value into c.SeriesColors.
+func (c *config) setSeriesColors(value string) error {
+	c.SeriesColors = map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		series, color := splitPair(pair)
+		if series == "" {
+			continue
+		}
+		if color == "" {
+			return fmt.Errorf("series %q in -series-colors has no color", series)
+		}
+		c.SeriesColors[series] = color
+	}
+	return nil
+}
+
+// logAxisCharts are the charts -log-axis applies to.
+var logAxisCharts = []string{"content-size", "size-vs-speed"}
+
+// setLogAxis parses a -log-axis value into c.LogAxis.
+func (c *config) setLogAxis(value string) error {
+	c.LogAxis = map[string]bool{}
+	for _, chart := range strings.Split(value, ",") {
+		chart = strings.TrimSpace(chart)
+		if chart == "" {
+			continue
+		}
+		if !contains(logAxisCharts, chart) {
+			return fmt.Errorf("-log-axis supports %s, got %q", strings.Join(logAxisCharts, ", "), chart)
+		}
+		c.LogAxis[chart] = true
+	}
+	return nil
+}
+
+// cssLength matches the CSS lengths charts can be sized with. A bare number
+// is taken as pixels.
+var cssLength = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(px|%|vw|vh|em|rem)?$`)
+
+// cssLengthFlag returns a flag setter that checks a value is a cssLength
+// and stores it in length, in pixels if no unit is given.
+func cssLengthFlag(length *string) func(string) error {
+	return func(value string) error {
+		value = strings.TrimSpace(value)
+		m := cssLength.FindStringSubmatch(value)
+		if m == nil {
+			return fmt.Errorf("%q is not a CSS length such as 1200px or 90%%", value)
+		}
+		if m[2] == "" {
+			value += "px"
+		}
+		*length = value
+		return nil
+	}
+}
+
+// templateFlag returns a flag setter that parses a chart=template value
+// into templates. The template is checked against an empty chartStats so a
+// bad field name fails here rather than halfway through rendering.
+func templateFlag(templates *map[string]*template.Template) func(string) error {
+	return func(value string) error {
+		chart, text := splitPair(value)
+		if !isChartName(chart) {
+			return fmt.Errorf("unknown chart %q", chart)
+		}
+		t, err := template.New(chart).Parse(text)
+		if err != nil {
+			return err
+		}
+		if err := t.Execute(ioutil.Discard, chartStats{}); err != nil {
+			return err
+		}
+		if *templates == nil {
+			*templates = map[string]*template.Template{}
+		}
+		(*templates)[chart] = t
+		return nil
+	}
+}
+
+// setPercentiles parses a -percentiles value into c.Percentiles.
+func (c *config) setPercentiles(value string) error {
+	c.Percentiles = nil
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(field, 64)
+		if err != nil || p < 0 || p > 100 {
+			return fmt.Errorf("percentile must be a number between 0 and 100, got %q", field)
+		}
+		if n := len(c.Percentiles); n > 0 && p <= c.Percentiles[n-1] {
+			return fmt.Errorf("percentiles must be in ascending order, got %g after %g", p, c.Percentiles[n-1])
+		}
+		c.Percentiles = append(c.Percentiles, p)
+	}
+	return nil
+}
+
+// pageConfig declares one page in a -config file. Log defaults to
+// <logs-dir>/<name>.log and Charts to every chart of the page's type.
+type pageConfig struct {
+	Name   string   `json:"name"`
+	Log    string   `json:"log,omitempty"`
+	Type   string   `json:"type"`
+	Title  string   `json:"title,omitempty"`
+	Charts []string `json:"charts,omitempty"`
+}
+
+// loadPageConfig reads the pages declared in the -config file at path into
+// matrixFiles and batteryMeasurementFiles, with their logs, titles and
+// charts, its series colors into c.SeriesColors where -series-colors
+// doesn't already set them, and its hidden series into c.HiddenSeries for
+// the charts -hidden-series leaves out.
+func (c *config) loadPageConfig(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Pages  []pageConfig        `json:"pages"`
+		Colors map[string]string   `json:"colors"`
+		Hidden map[string][]string `json:"hidden"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return inFile(path, fieldError("", err))
+	}
+	matrixFiles, batteryMeasurementFiles = nil, nil
+	c.PageTitles, c.PageCharts = map[string]string{}, map[string][]string{}
+	for i, p := range file.Pages {
+		field := fmt.Sprintf("pages[%d]", i)
+		if p.Name == "" {
+			return &fileError{File: path, Field: field, Message: "page has no name"}
+		}
+		if contains(matrixFiles, p.Name) || contains(batteryMeasurementFiles, p.Name) {
+			return &fileError{File: path, Field: field, Message: fmt.Sprintf("page %q is declared twice", p.Name)}
+		}
+		var valid []string
+		switch p.Type {
+		case "matrix":
+			matrixFiles = append(matrixFiles, p.Name)
+			for _, ch := range matrixCharts {
+				valid = append(valid, ch.name)
+			}
+		case "battery":
+			batteryMeasurementFiles = append(batteryMeasurementFiles, p.Name)
+			for _, ch := range batteryCharts {
+				valid = append(valid, ch.name)
+			}
+		default:
+			return &fileError{File: path, Field: field + ".type", Message: fmt.Sprintf("must be matrix or battery, got %q", p.Type)}
+		}
+		for _, chart := range p.Charts {
+			if !contains(valid, chart) {
+				return &fileError{File: path, Field: field + ".charts", Message: fmt.Sprintf("no %s chart %q", p.Type, chart)}
+			}
+		}
+		if p.Log != "" {
+			if c.LogSources == nil {
+				c.LogSources = map[string]string{}
+			}
+			c.LogSources[p.Name] = p.Log
+		}
+		if p.Title != "" {
+			c.PageTitles[p.Name] = p.Title
+		}
+		if p.Charts != nil {
+			c.PageCharts[p.Name] = p.Charts
+		}
+	}
+	for series, color := range file.Colors {
+		if color == "" {
+			return &fileError{File: path, Field: "colors", Message: fmt.Sprintf("series %q has no color", series)}
+		}
+		if _, ok := c.SeriesColors[series]; ok {
+			continue
+		}
+		if c.SeriesColors == nil {
+			c.SeriesColors = map[string]string{}
+		}
+		c.SeriesColors[series] = color
+	}
+	for chart, series := range file.Hidden {
+		if !isChartName(chart) {
+			return &fileError{File: path, Field: "hidden", Message: fmt.Sprintf("unknown chart %q", chart)}
+		}
+		if _, ok := c.HiddenSeries[chart]; ok {
+			continue
+		}
+		if c.HiddenSeries == nil {
+			c.HiddenSeries = map[string][]string{}
+		}
+		c.HiddenSeries[chart] = series
+	}
+	return nil
+}
+
+// pageTitle returns the title of the named page, from the -config file or
+// else def.
+func pageTitle(page, def string) string {
+	if title, ok := cfg.PageTitles[page]; ok {
+		return title
+	}
+	return def
+}
+
+// showsChart reports whether the named page includes the named chart.
+func showsChart(page, chart string) bool {
+	charts, ok := cfg.PageCharts[page]
+	return !ok || contains(charts, chart)
+}
+
+// splitPair splits a "key=value" pair, trimming spaces around both.
+func splitPair(pair string) (string, string) {
+	i := strings.Index(pair, "=")
+	if i < 0 {
+		return strings.TrimSpace(pair), ""
+	}
+	return strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *config)
+		want   string
+	}{
+		{"negative read-retries", func(c *config) { c.ReadRetries = -1 }, "-read-retries"},
+		{"negative read-retry-delay", func(c *config) { c.ReadRetryDelay = -time.Second }, "-read-retry-delay"},
+		{"negative slowest-n", func(c *config) { c.SlowestN = -1 }, "-slowest-n"},
+		{"negative flakiest-n", func(c *config) { c.FlakiestN = -1 }, "-flakiest-n"},
+		{"area opacity above 1", func(c *config) { c.AreaOpacity = 1.5 }, "-area-opacity"},
+		{"negative area opacity", func(c *config) { c.AreaOpacity = -0.1 }, "-area-opacity"},
+		{"unknown tooltip kind", func(c *config) { c.TooltipTriggers = map[string]string{"lien": "item"} }, "-tooltip-trigger"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cfg
+			tt.modify(&c)
+			err := c.validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validate() = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	c := cfg
+	if err := c.validate(); err != nil {
+		t.Errorf("validate() = %v for the defaults", err)
+	}
+}
+
+// TestConfigHiddenSeries loads hidden series from a -config file, which
+// hide them in the chart's legend unless -hidden-series names the chart.
+func TestConfigHiddenSeries(t *testing.T) {
+	useTestdata(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	file := `{
+		"pages": [{"name": "fixture_matrix", "type": "matrix"}],
+		"hidden": {"ble-to-ipfs": ["5-point moving average"], "ble-to-wifi": ["BLE to Wifi"]}
+	}`
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.setHiddenSeries("ble-to-wifi=Other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.loadPageConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"ble-to-ipfs": {"5-point moving average"}, "ble-to-wifi": {"Other"}}
+	if !reflect.DeepEqual(cfg.HiddenSeries, want) {
+		t.Errorf("hidden series %v, want %v", cfg.HiddenSeries, want)
+	}
+
+	data, err := loadMatrix(filepath.Join("testdata", "fixture_matrix.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart := bleToIpfs([]dataset{{Data: data}})
+	hideSeries("ble-to-ipfs", chart)
+	if selected, ok := chart.Legend.Selected["5-point moving average"]; !ok || selected {
+		t.Errorf("legend selection %v doesn't hide the moving average", chart.Legend.Selected)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"hidden": {"no-such-chart": ["x"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.loadPageConfig(path); err == nil || !strings.Contains(err.Error(), "no-such-chart") {
+		t.Errorf("loading hidden series for an unknown chart: got error %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// datadogMaxPayload caps the bytes of JSON posted per request, the v1
+	// series intake's limit on an uncompressed payload.
+	datadogMaxPayload = 3200000
+	datadogAttempts   = 4
+)
+
+// datadogBackoff is the wait before the first retry of a failed post,
+// doubling with each further attempt.
+var datadogBackoff = time.Second
+
+// datadogSeries is one metric series in the v1 series intake format. Points
+// are [unix seconds, value] pairs.
+type datadogSeries struct {
+	Metric string       `json:"metric"`
+	Type   string       `json:"type"`
+	Points [][2]float64 `json:"points"`
+	Tags   []string     `json:"tags"`
+}
+
+// datadogMetrics turns a matrix page into series, each point carrying the
+// time it was originally logged at in unix seconds, and delays in seconds,
+// whether the log records seconds or milliseconds. Zero delays and speeds
+// mean the value was never recorded and are left out.
+func datadogMetrics(page string, data *matrix) []datadogSeries {
+	series := []datadogSeries{}
+	add := func(metric string, points [][2]float64, tags ...string) {
+		if len(points) == 0 {
+			return
+		}
+		series = append(series, datadogSeries{
+			Metric: metric,
+			Type:   "gauge",
+			Points: points,
+			Tags:   append([]string{"page:" + page}, tags...),
+		})
+	}
+
+	nodes := nodeIDs(data)
+	for _, id := range nodes {
+		delays, speeds := [][2]float64{}, [][2]float64{}
+		for _, k := range data.NodeMatrix[id].ConnectionHistory {
+			if k.IPFSConnectedAt != 0 && k.BLEDiscoveredAt != 0 {
+				delays = append(delays, [2]float64{unixSeconds(k.BLEDiscoveredAt), durationSeconds(k.BLEDiscoveredAt, k.IPFSConnectedAt)})
+			}
+			if k.Speed != 0 && k.WifiConnectedAt != 0 {
+				speeds = append(speeds, [2]float64{unixSeconds(k.WifiConnectedAt), float64(k.Speed)})
+			}
+		}
+		add("datahop.discovery.delay", delays, "peer:"+id)
+		add("datahop.link.speed", speeds, "peer:"+id)
+	}
+
+	cids := contentIDs(data)
+	for _, cid := range cids {
+		c := data.ContentMatrix[cid]
+		if c.DownloadFinishedAt == 0 {
+			continue
+		}
+		tags := []string{"content:" + c.Tag}
+		for _, p := range providersOf(c) {
+			tags = append(tags, "peer:"+p)
+		}
+		add("datahop.download.speed", [][2]float64{{unixSeconds(c.DownloadFinishedAt), float64(c.AvgSpeed)}}, tags...)
+	}
+	return series
+}
+
+// unixSeconds returns a log timestamp in the unix seconds Datadog expects.
+func unixSeconds(t int64) float64 {
+	return float64(timestampTime(t).Unix())
+}
+
+// exportDatadog posts the metrics of every matrix page to Datadog in
+// batches of at most datadogMaxPayload bytes.
+func exportDatadog(apiKey, site string) error {
+	series := []datadogSeries{}
+	for _, pageName := range matrixFiles {
+		data, err := loadMatrixPage(pageName)
+		if err != nil {
+			return err
+		}
+		series = append(series, datadogMetrics(pageName, data)...)
+	}
+	batches, err := datadogBatches(series, datadogMaxPayload)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("https://api.%s/api/v1/series", site)
+	for i, body := range batches {
+		if err := postDatadog(url, apiKey, body); err != nil {
+			return fmt.Errorf("datadog batch %d of %d: %w", i+1, len(batches), err)
+		}
+	}
+	slog.Info("posted to Datadog", "series", len(series))
+	return nil
+}
+
+// datadogBatches encodes series as series intake payloads of at most limit
+// bytes each, keeping the series in order. A series too big to fit in a
+// payload by itself is an error.
+func datadogBatches(series []datadogSeries, limit int) ([][]byte, error) {
+	const head, tail = `{"series":[`, `]}`
+	var batches [][]byte
+	var batch []byte
+	for i, s := range series {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(head)+len(b)+len(tail) > limit {
+			return nil, fmt.Errorf("datadog series %d (%s) is %d bytes, over the %d byte payload limit", i, s.Metric, len(b), limit)
+		}
+		if batch != nil && len(batch)+1+len(b)+len(tail) > limit {
+			batches = append(batches, append(batch, tail...))
+			batch = nil
+		}
+		if batch == nil {
+			batch = append([]byte(head), b...)
+		} else {
+			batch = append(append(batch, ','), b...)
+		}
+	}
+	if batch != nil {
+		batches = append(batches, append(batch, tail...))
+	}
+	return batches, nil
+}
+
+// postDatadog posts one batch, retrying with exponential backoff on network
+// errors, 429 and 5xx. Any other 4xx means the batch itself is bad and is
+// returned straight away. Each attempt is given -http-timeout, so a hung
+// intake is retried rather than waited on forever.
+func postDatadog(url, apiKey string, body []byte) error {
+	client := &http.Client{Timeout: cfg.HTTPTimeout}
+	backoff := datadogBackoff
+	var err error
+	for attempt := 1; attempt <= datadogAttempts; attempt++ {
+		if attempt > 1 {
+			slog.Warn("datadog request failed, retrying", "err", err, "backoff", backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("DD-API-KEY", apiKey)
+		var resp *http.Response
+		resp, err = client.Do(req)
+		if err != nil {
+			continue
+		}
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+		default:
+			return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+		}
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDatadogBatches(t *testing.T) {
+	var series []datadogSeries
+	for i := 0; i < 100; i++ {
+		series = append(series, datadogSeries{
+			Metric: "datahop.link.speed",
+			Type:   "gauge",
+			Points: [][2]float64{{1700000000, float64(i)}},
+			Tags:   []string{"page:fixture", fmt.Sprintf("peer:QmNode%d", i)},
+		})
+	}
+	const limit = 1000
+	batches, err := datadogBatches(series, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) < 2 {
+		t.Fatalf("%d series went in %d batch, want them split", len(series), len(batches))
+	}
+	var got []datadogSeries
+	for i, b := range batches {
+		if len(b) > limit {
+			t.Errorf("batch %d is %d bytes, over %d", i, len(b), limit)
+		}
+		var payload map[string][]datadogSeries
+		if err := json.Unmarshal(b, &payload); err != nil {
+			t.Fatalf("batch %d: %v", i, err)
+		}
+		got = append(got, payload["series"]...)
+	}
+	if !reflect.DeepEqual(got, series) {
+		t.Error("batches don't hold the series in order")
+	}
+
+	if _, err := datadogBatches(series[:1], 20); err == nil {
+		t.Error("a series over the limit by itself was batched")
+	}
+	if batches, err := datadogBatches(nil, limit); err != nil || len(batches) != 0 {
+		t.Errorf("no series gave %d batches and error %v", len(batches), err)
+	}
+}
+
+// TestPostDatadogTimeout posts to an intake that never answers, which must
+// time out and be retried rather than hang.
+func TestPostDatadogTimeout(t *testing.T) {
+	useTestdata(t)
+	cfg.HTTPTimeout = 50 * time.Millisecond
+	saved := datadogBackoff
+	datadogBackoff = time.Millisecond
+	t.Cleanup(func() { datadogBackoff = saved })
+	release := make(chan struct{})
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	done := make(chan error, 1)
+	go func() { done <- postDatadog(srv.URL, "key", []byte(`{"series":[]}`)) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("posting to a hung intake returned no error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("posting to a hung intake didn't time out")
+	}
+	if n := attempts.Load(); n != datadogAttempts {
+		t.Errorf("made %d attempts, want %d", n, datadogAttempts)
+	}
+}
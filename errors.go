@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// fileError is an error found in a log file. Field is the path of the
+// offending value within the file, when known.
+type fileError struct {
+	File    string `json:"file,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+
+	// err is the error Message was taken from, if any.
+	err error
+}
+
+func (e *fileError) Error() string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{e.File, e.Field, e.Message} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ": ")
+}
+
+func (e *fileError) Unwrap() error { return e.err }
+
+// fieldError attributes a decoding error to field, extended with the struct
+// field a JSON type mismatch was found in. Errors already attributed are
+// returned as they are.
+func fieldError(field string, err error) error {
+	var fe *fileError
+	if errors.As(err, &fe) {
+		return err
+	}
+	var ute *json.UnmarshalTypeError
+	if errors.As(err, &ute) && ute.Field != "" {
+		if field != "" {
+			field += "."
+		}
+		field += ute.Field
+	}
+	return &fileError{Field: field, Message: err.Error(), err: err}
+}
+
+// inFile attributes err to the log file at path.
+func inFile(path string, err error) error {
+	var fe *fileError
+	if errors.As(err, &fe) {
+		fe.File = path
+		return fe
+	}
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &fileError{File: path, Message: err.Error(), err: err}
+}
+
+// reportError writes err to stderr, as a JSON line with -json-errors and as
+// a log line otherwise.
+func reportError(err error) {
+	if !cfg.JSONErrors {
+		slog.Error(err.Error())
+		return
+	}
+	fe := &fileError{Message: err.Error()}
+	errors.As(err, &fe)
+	json.NewEncoder(os.Stderr).Encode(fe)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const exportAPIPrefix = "/api/export/"
+
+// connectionRecord is one ConnectionInfo flattened with where it came from.
+type connectionRecord struct {
+	Type  string `json:"type"`
+	Page  string `json:"page"`
+	Node  string `json:"node"`
+	Index int    `json:"index"`
+	ConnectionInfo
+}
+
+// contentRecord is one ContentMatrix item flattened with where it came from.
+type contentRecord struct {
+	Type string `json:"type"`
+	Page string `json:"page"`
+	CID  string `json:"cid"`
+	ContentMatrix
+}
+
+// writeNDJSON writes every connection and content item of data to w, one
+// JSON record per line, in a stable order.
+func writeNDJSON(w io.Writer, page string, data *matrix) error {
+	enc := json.NewEncoder(w)
+	nodes := nodeIDs(data)
+	for _, id := range nodes {
+		for i, k := range data.NodeMatrix[id].ConnectionHistory {
+			if err := enc.Encode(connectionRecord{Type: "connection", Page: page, Node: id, Index: i, ConnectionInfo: k}); err != nil {
+				return err
+			}
+		}
+	}
+	cids := contentIDs(data)
+	for _, cid := range cids {
+		if err := enc.Encode(contentRecord{Type: "content", Page: page, CID: cid, ContentMatrix: data.ContentMatrix[cid]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportNDJSON writes the records of every matrix page to out.
+func exportNDJSON(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	for _, pageName := range matrixFiles {
+		data, err := loadMatrixPage(pageName)
+		if err != nil {
+			return err
+		}
+		if err := writeNDJSON(w, pageName, data); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// batteryCSVHeader names the battery CSV columns, with their units.
+var batteryCSVHeader = []string{"device", "firmware", "data_transfer_mb", "transfer_interval_s", "battery_consumption_pct", "consumption_per_mb_pct"}
+
+// writeBatteryCSV writes the battery measurements to w as CSV with numeric
+// fields parsed and the consumption per MB computed. Fields that don't
+// parse are left empty.
+func writeBatteryCSV(w io.Writer, data *BatteryMeasurements) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(batteryCSVHeader); err != nil {
+		return err
+	}
+	number := func(s string) (float64, string) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return 0, ""
+		}
+		return f, strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	for _, v := range data.BatteryMeasurement {
+		device := v.Device
+		if device == "" {
+			device = "default"
+		}
+		mb, transfer := number(v.DataTransfer)
+		_, interval := number(v.TransferInterval)
+		perMB := ""
+		if mb > 0 {
+			perMB = strconv.FormatFloat(float64(v.BatteryConsumption)/mb, 'f', 4, 64)
+		}
+		if err := cw.Write([]string{device, v.Firmware, transfer, interval, strconv.FormatFloat(float64(v.BatteryConsumption), 'f', -1, 64), perMB}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportBatteryCSV writes the measurements of every battery page to out.
+func exportBatteryCSV(out io.Writer) error {
+	merged := &BatteryMeasurements{}
+	for _, pageName := range batteryMeasurementFiles {
+		data, err := loadBatteryMeasurements(logPath(pageName))
+		if err != nil {
+			return err
+		}
+		merged.BatteryMeasurement = append(merged.BatteryMeasurement, data.BatteryMeasurement...)
+	}
+	return writeBatteryCSV(out, merged)
+}
+
+// seriesCSVColumns are the values making up a matrix page's CSV, those
+// plotted on its BLE to Wifi, BLE to IPFS and download speed charts, with
+// the header of each column. bits is the size of the float the values were
+// read from, so they are written back as logged.
+var seriesCSVColumns = []struct {
+	header string
+	values func(*matrix) []float64
+	bits   int
+}{
+	{"ble_to_wifi_s", wifiDelays, 64},
+	{"ble_to_ipfs_s", discoveryDelays, 64},
+	{"download_speed_mbps", downloadSpeeds, 32},
+}
+
+// writeSeriesCSV writes the values plotted on the BLE to Wifi, BLE to IPFS
+// and download speed charts to w, one column each, however -max-points
+// thins the charts. The series differ in length, so shorter columns are
+// padded with empty fields.
+func writeSeriesCSV(w io.Writer, data *matrix) error {
+	columns := make([][]float64, 0, len(seriesCSVColumns))
+	header := []string{"index"}
+	rows := 0
+	for _, c := range seriesCSVColumns {
+		values := c.values(data)
+		if len(values) > rows {
+			rows = len(values)
+		}
+		columns = append(columns, values)
+		header = append(header, c.header)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < rows; i++ {
+		record := []string{strconv.Itoa(i)}
+		for j, values := range columns {
+			field := ""
+			if i < len(values) {
+				field = strconv.FormatFloat(values[i], 'f', -1, seriesCSVColumns[j].bits)
+			}
+			record = append(record, field)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// renderSeriesCSV writes the series CSV of a matrix page next to its HTML,
+// where the dashboard serves it for download.
+func renderSeriesCSV(pageName string, data *matrix) error {
+	f, err := os.Create(outPath(pageName + ".csv"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeSeriesCSV(f, data)
+}
+
+// serveExport answers /api/export/{page}.ndjson with a matrix page's records
+// and /api/export/{page}.csv with a battery page's measurements.
+func serveExport(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, exportAPIPrefix)
+	switch ext := path.Ext(name); {
+	case ext == ".ndjson" && contains(matrixFiles, strings.TrimSuffix(name, ext)):
+		pageName := strings.TrimSuffix(name, ext)
+		data, err := loadMatrixPage(pageName)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if err := writeNDJSON(w, pageName, data); err != nil {
+			slog.Error("ndjson export failed", "err", err)
+		}
+	case ext == ".csv" && contains(batteryMeasurementFiles, strings.TrimSuffix(name, ext)):
+		data, err := loadBatteryMeasurements(logPath(strings.TrimSuffix(name, ext)))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		if err := writeBatteryCSV(w, data); err != nil {
+			slog.Error("csv export failed", "err", err)
+		}
+	default:
+		valid := make([]string, 0, len(matrixFiles)+len(batteryMeasurementFiles))
+		for _, f := range matrixFiles {
+			valid = append(valid, f+".ndjson")
+		}
+		for _, f := range batteryMeasurementFiles {
+			valid = append(valid, f+".csv")
+		}
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown export %q", name), Valid: valid})
+	}
+}
@@ -1,5 +1,17 @@
 module github.com/datahop/matrix-charts
 
-go 1.17
+go 1.22
 
-require github.com/go-echarts/go-echarts/v2 v2.2.4
+require (
+	github.com/go-echarts/go-echarts/v2 v2.2.4
+	google.golang.org/grpc v1.45.0
+	google.golang.org/protobuf v1.28.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.2 // indirect
+	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
+	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd // indirect
+	golang.org/x/text v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+)
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/datahop/matrix-charts/metricspb"
+)
+
+// metricsServer implements the Metrics gRPC service over the matrix logs.
+type metricsServer struct {
+	metricspb.UnimplementedMetricsServer
+}
+
+// loadPage loads a matrix page, reporting unknown pages as NotFound.
+func (metricsServer) loadPage(page string) (*matrix, error) {
+	if !contains(matrixFiles, page) {
+		return nil, status.Errorf(codes.NotFound, "unknown page %q, valid pages are %v", page, matrixFiles)
+	}
+	data, err := loadMatrixPage(page)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return data, nil
+}
+
+func (s metricsServer) GetSummary(ctx context.Context, req *metricspb.SummaryRequest) (*metricspb.Summary, error) {
+	data, err := s.loadPage(req.Page)
+	if err != nil {
+		return nil, err
+	}
+	connections := 0
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		connections += len(v.ConnectionHistory)
+	}
+	return &metricspb.Summary{
+		Page:                  req.Page,
+		Nodes:                 int32(len(nodeIDs(data))),
+		Connections:           int32(connections),
+		Contents:              int32(len(data.ContentMatrix)),
+		ConnectionSuccessRate: connectionSuccessRate(data),
+		MeanDownloadSpeed:     meanDownloadSpeed(data),
+		TotalUptime:           data.TotalUptime,
+	}, nil
+}
+
+// sampleTime converts a log timestamp, in seconds or milliseconds, to the
+// unix seconds a Sample carries. A zero timestamp stays zero.
+func sampleTime(t int64) int64 {
+	return timestampTime(t).Unix()
+}
+
+func (s metricsServer) StreamSamples(req *metricspb.SamplesRequest, stream metricspb.Metrics_StreamSamplesServer) error {
+	data, err := s.loadPage(req.Page)
+	if err != nil {
+		return err
+	}
+	nodes := nodeIDs(data)
+	for _, id := range nodes {
+		for i, k := range data.NodeMatrix[id].ConnectionHistory {
+			err := stream.Send(&metricspb.Sample{
+				Node:            id,
+				Index:           int32(i),
+				BleDiscoveredAt: sampleTime(k.BLEDiscoveredAt),
+				WifiConnectedAt: sampleTime(k.WifiConnectedAt),
+				IpfsConnectedAt: sampleTime(k.IPFSConnectedAt),
+				DisconnectedAt:  sampleTime(k.DisconnectedAt),
+				Rssi:            int32(k.RSSI),
+				Speed:           int32(k.Speed),
+				Frequency:       int32(k.Frequency),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// serveGRPC runs the Metrics service on addr until ctx is cancelled, then
+// stops it gracefully, cutting off streams still open after
+// shutdownTimeout, and returns.
+func serveGRPC(ctx context.Context, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer()
+	metricspb.RegisterMetricsServer(srv, metricsServer{})
+
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("running gRPC server", "addr", addr)
+		errc <- srv.Serve(lis)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			srv.Stop()
+		}
+		return nil
+	}
+}
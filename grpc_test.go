@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/datahop/matrix-charts/metricspb"
+)
+
+// sampleStream collects the samples StreamSamples sends.
+type sampleStream struct {
+	grpc.ServerStream
+	samples []*metricspb.Sample
+}
+
+func (s *sampleStream) Send(sample *metricspb.Sample) error {
+	s.samples = append(s.samples, sample)
+	return nil
+}
+
+// TestStreamSamplesSeconds streams samples from a log with millisecond
+// timestamps, which must arrive in unix seconds like those of a log in
+// seconds.
+func TestStreamSamplesSeconds(t *testing.T) {
+	useTestdata(t)
+	dir := t.TempDir()
+	cfg.LogSources = map[string]string{}
+	logs := map[string]string{
+		"seconds":      `{"NodeMatrix": {"QmNodeA": {"ConnectionHistory": [{"BLEDiscoveredAt": 1700000000, "WifiConnectedAt": 1700000002, "IPFSConnectedAt": 1700000005}]}}}`,
+		"milliseconds": `{"NodeMatrix": {"QmNodeA": {"ConnectionHistory": [{"BLEDiscoveredAt": 1700000000000, "WifiConnectedAt": 1700000002400, "IPFSConnectedAt": 1700000005900}]}}}`,
+	}
+	for page, log := range logs {
+		path := filepath.Join(dir, page+".log")
+		if err := os.WriteFile(path, []byte(log), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg.LogSources[page] = path
+		matrixFiles = append(matrixFiles, page)
+	}
+	var streams [2]sampleStream
+	for i, page := range []string{"seconds", "milliseconds"} {
+		if err := (metricsServer{}).StreamSamples(&metricspb.SamplesRequest{Page: page}, &streams[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, want := streams[1].samples[0], streams[0].samples[0]
+	if want.BleDiscoveredAt != 1700000000 {
+		t.Errorf("seconds log streamed %v", want)
+	}
+	if got.BleDiscoveredAt != want.BleDiscoveredAt || got.WifiConnectedAt != want.WifiConnectedAt ||
+		got.IpfsConnectedAt != want.IpfsConnectedAt || got.DisconnectedAt != 0 {
+		t.Errorf("millisecond log streamed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/components"
+)
+
+const liveAPIPrefix = "/api/live/"
+
+// liveChart is the part of one chart's option that changes with the data,
+// addressed by the chart's position on the page.
+type liveChart struct {
+	Index  int             `json:"index"`
+	Option json.RawMessage `json:"option"`
+}
+
+// liveUpdate carries the charts of a page that changed in a version.
+type liveUpdate struct {
+	Version int         `json:"version"`
+	Charts  []liveChart `json:"charts"`
+}
+
+// livePage is the latest published state of one page and who listens to it.
+type livePage struct {
+	version     int
+	options     []json.RawMessage
+	subscribers map[chan liveUpdate]bool
+}
+
+// liveHub fans re-rendered chart data out to browsers watching a page,
+// either over a WebSocket or by polling.
+type liveHub struct {
+	mu     sync.Mutex
+	pages  map[string]*livePage
+	closed bool
+}
+
+var live = &liveHub{pages: map[string]*livePage{}}
+
+func (h *liveHub) page(name string) *livePage {
+	p, ok := h.pages[name]
+	if !ok {
+		p = &livePage{subscribers: map[chan liveUpdate]bool{}}
+		h.pages[name] = p
+	}
+	return p
+}
+
+// publish records the charts just rendered for a page and pushes the ones
+// whose data changed since the last publish to every subscriber.
+func (h *liveHub) publish(name string, page *components.Page) error {
+	options := make([]json.RawMessage, len(page.Charts))
+	for i, c := range page.Charts {
+		option, err := liveOption(c.(components.Charter))
+		if err != nil {
+			return err
+		}
+		options[i] = option
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := h.page(name)
+	update := liveUpdate{}
+	for i, option := range options {
+		if i >= len(p.options) || !bytes.Equal(p.options[i], option) {
+			update.Charts = append(update.Charts, liveChart{Index: i, Option: option})
+		}
+	}
+	if len(update.Charts) == 0 && len(p.options) == len(options) {
+		return nil
+	}
+	p.version++
+	p.options = options
+	update.Version = p.version
+	for ch := range p.subscribers {
+		select {
+		case ch <- update:
+		default:
+			// A subscriber that can't keep up gets dropped; its browser
+			// falls back to polling.
+			delete(p.subscribers, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// snapshot returns every chart of a page if it changed after version since.
+func (h *liveHub) snapshot(name string, since int) (liveUpdate, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := h.page(name)
+	if p.version <= since {
+		return liveUpdate{}, false
+	}
+	update := liveUpdate{Version: p.version}
+	for i, option := range p.options {
+		update.Charts = append(update.Charts, liveChart{Index: i, Option: option})
+	}
+	return update, true
+}
+
+func (h *liveHub) subscribe(name string) chan liveUpdate {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan liveUpdate, 8)
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.page(name).subscribers[ch] = true
+	return ch
+}
+
+func (h *liveHub) unsubscribe(name string, ch chan liveUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p := h.page(name)
+	if p.subscribers[ch] {
+		delete(p.subscribers, ch)
+		close(ch)
+	}
+}
+
+// close ends every subscription. Hijacked WebSocket connections aren't
+// tracked by http.Server.Shutdown, so this is what lets them finish.
+func (h *liveHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, p := range h.pages {
+		for ch := range p.subscribers {
+			delete(p.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// liveOption extracts the data-bearing part of a chart's option.
+func liveOption(chart components.Charter) (json.RawMessage, error) {
+	c, ok := chart.(interface{ JSON() map[string]interface{} })
+	if !ok {
+		return nil, fmt.Errorf("chart type %s has no options", chart.Type())
+	}
+	full := c.JSON()
+	option := map[string]interface{}{"series": full["series"]}
+	for _, key := range []string{"xAxis", "yAxis"} {
+		if v, ok := full[key]; ok {
+			option[key] = v
+		}
+	}
+	return json.Marshal(option)
+}
+
+// serveLive answers /api/live/{page} WebSocket upgrades with a stream of
+// updates, and /api/live/{page}.json?since=N polls with the page's charts
+// when they changed after version N.
+func serveLive(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, liveAPIPrefix)
+	if strings.HasSuffix(name, ".json") {
+		since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+		update, ok := live.snapshot(strings.TrimSuffix(name, ".json"), since)
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, update)
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "expected a WebSocket upgrade"})
+		return
+	}
+	conn, rw, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ch := live.subscribe(name)
+	defer live.unsubscribe(name, ch)
+	gone := make(chan struct{})
+	go func() {
+		// Browsers only send control frames here; reading them tells us
+		// when the page goes away.
+		discardWebSocketFrames(rw.Reader)
+		close(gone)
+	}()
+	for {
+		select {
+		case update, ok := <-ch:
+			if !ok {
+				writeWebSocketFrame(rw.Writer, 0x8, nil)
+				return
+			}
+			b, err := json.Marshal(update)
+			if err != nil {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := writeWebSocketFrame(rw.Writer, 0x1, b); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// webSocketGUID is the fixed key suffix from RFC 6455 section 1.3.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, nil, fmt.Errorf("response writer can't be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+// writeWebSocketFrame writes one unmasked, unfragmented server frame.
+func writeWebSocketFrame(w *bufio.Writer, opcode byte, payload []byte) error {
+	w.WriteByte(0x80 | opcode)
+	switch n := len(payload); {
+	case n < 126:
+		w.WriteByte(byte(n))
+	case n <= 0xFFFF:
+		w.WriteByte(126)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(127)
+		binary.Write(w, binary.BigEndian, uint64(n))
+	}
+	w.Write(payload)
+	return w.Flush()
+}
+
+// discardWebSocketFrames reads client frames until a close frame or an
+// error, throwing their payloads away.
+func discardWebSocketFrames(r *bufio.Reader) {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		opcode := header[0] & 0x0F
+		n := uint64(header[1] & 0x7F)
+		switch n {
+		case 126:
+			var ext uint16
+			if binary.Read(r, binary.BigEndian, &ext) != nil {
+				return
+			}
+			n = uint64(ext)
+		case 127:
+			if binary.Read(r, binary.BigEndian, &n) != nil {
+				return
+			}
+		}
+		if header[1]&0x80 != 0 {
+			n += 4 // masking key
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, int64(n)); err != nil {
+			return
+		}
+		if opcode == 0x8 {
+			return
+		}
+	}
+}
+
+// liveScript returns the script that keeps a rendered page's charts in sync
+// with the server, over a WebSocket when possible and by polling otherwise.
+func liveScript(pageName string) string {
+	if !cfg.Live {
+		return ""
+	}
+	return fmt.Sprintf(`<script type="text/javascript">
+(function () {
+    var page = %q, version = 0, items = document.querySelectorAll(".item");
+    function apply(update) {
+        version = update.version;
+        update.charts.forEach(function (c) {
+            var chart = items[c.index] && echarts.getInstanceByDom(items[c.index]);
+            if (chart) { chart.setOption(c.option); }
+        });
+    }
+    function poll() {
+        setInterval(function () {
+            fetch("%s" + page + ".json?since=" + version).then(function (r) {
+                if (r.status === 200) { r.json().then(apply); }
+            });
+        }, 5000);
+    }
+    if (!("WebSocket" in window)) { poll(); return; }
+    var proto = location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(proto + location.host + "%s" + page);
+    ws.onmessage = function (e) { apply(JSON.parse(e.data)); };
+    ws.onclose = function () { poll(); };
+})();
+</script>`, pageName, liveAPIPrefix, liveAPIPrefix)
+}
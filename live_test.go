@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readServerFrame reads one unmasked frame as writeWebSocketFrame writes
+// it, returning its opcode and payload.
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[0]&0x80 == 0 {
+		t.Error("frame isn't final")
+	}
+	if header[1]&0x80 != 0 {
+		t.Error("server frame is masked")
+	}
+	n := uint64(header[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext uint16
+		binary.Read(r, binary.BigEndian, &ext)
+		n = uint64(ext)
+	case 127:
+		binary.Read(r, binary.BigEndian, &n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// clientFrame returns a masked client frame, as a browser sends it.
+func clientFrame(opcode byte, payload []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte(0x80 | opcode)
+	switch n := len(payload); {
+	case n < 126:
+		b.WriteByte(0x80 | byte(n))
+	case n <= 0xFFFF:
+		b.WriteByte(0x80 | 126)
+		binary.Write(&b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(0x80 | 127)
+		binary.Write(&b, binary.BigEndian, uint64(n))
+	}
+	key := [4]byte{0x12, 0x34, 0x56, 0x78}
+	b.Write(key[:])
+	for i, c := range payload {
+		b.WriteByte(c ^ key[i%4])
+	}
+	return b.Bytes()
+}
+
+// payloadSizes cross each boundary of the frame length encoding: 7 bits,
+// 16 bits and 64 bits.
+var payloadSizes = []int{0, 1, 125, 126, 127, 0xFFFF, 0x10000, 0x10001}
+
+func TestWriteWebSocketFrame(t *testing.T) {
+	for _, n := range payloadSizes {
+		payload := bytes.Repeat([]byte{'x'}, n)
+		var buf bytes.Buffer
+		if err := writeWebSocketFrame(bufio.NewWriter(&buf), 0x1, payload); err != nil {
+			t.Fatal(err)
+		}
+		opcode, got := readServerFrame(t, &buf)
+		if opcode != 0x1 || !bytes.Equal(got, payload) {
+			t.Errorf("%d byte frame read back as opcode %#x with %d bytes", n, opcode, len(got))
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%d byte frame has %d bytes left over", n, buf.Len())
+		}
+	}
+}
+
+func TestDiscardWebSocketFrames(t *testing.T) {
+	var in bytes.Buffer
+	for _, n := range payloadSizes {
+		in.Write(clientFrame(0x9, bytes.Repeat([]byte{'p'}, n)))
+	}
+	in.Write(clientFrame(0x8, []byte{0x03, 0xE8}))
+	in.WriteString("after close")
+	r := bufio.NewReader(&in)
+	discardWebSocketFrames(r)
+	if rest, _ := io.ReadAll(r); string(rest) != "after close" {
+		t.Errorf("stopped with %q left, want to stop right after the close frame", rest)
+	}
+}
+
+// TestDiscardWebSocketFramesTruncated cuts a frame off mid-payload, as a
+// dropped connection does, which must end the read rather than block.
+func TestDiscardWebSocketFramesTruncated(t *testing.T) {
+	frame := clientFrame(0x1, bytes.Repeat([]byte{'p'}, 300))
+	discardWebSocketFrames(bufio.NewReader(bytes.NewReader(frame[:100])))
+}
+
+func TestUpgradeWebSocket(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		writeWebSocketFrame(rw.Writer, 0x1, []byte("hello"))
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The example handshake from RFC 6455 section 1.3.
+	io.WriteString(conn, "GET /api/live/page HTTP/1.1\r\nHost: example\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status %s, want 101", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept %q", got)
+	}
+	if opcode, payload := readServerFrame(t, r); opcode != 0x1 || string(payload) != "hello" {
+		t.Errorf("first frame opcode %#x payload %q", opcode, payload)
+	}
+}
+
+func TestUpgradeWebSocketWithoutKey(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/live/page", nil)
+	req.Header.Set("Upgrade", "websocket")
+	if _, _, err := upgradeWebSocket(rec, req); err == nil || !strings.Contains(rec.Body.String(), "Sec-WebSocket-Key") {
+		t.Errorf("upgrade without a key: err %v, body %q", err, rec.Body.String())
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logCache keeps decoded log files keyed by path so pages sharing a source
+// only parse it once. Each entry remembers the stamp of the log it was
+// decoded from and is only reused while the log still has that stamp, so a
+// changed log is parsed again. Logs given by URL have no stamp to check and
+// are never cached, so each load fetches them afresh. It is safe for
+// concurrent use by parallel renders.
+type logCache struct {
+	mu      sync.RWMutex
+	entries map[string]cachedLog
+}
+
+type cachedLog struct {
+	stamp fileStamp
+	v     interface{}
+}
+
+var parsedLogs = &logCache{entries: map[string]cachedLog{}}
+
+func (c *logCache) get(path string) (interface{}, bool) {
+	if isURL(path) {
+		return nil, false
+	}
+	c.mu.RLock()
+	e, ok := c.entries[path]
+	c.mu.RUnlock()
+	if !ok || e.stamp != stampOf(path) {
+		slog.Debug("log cache miss", "path", path)
+		return nil, false
+	}
+	slog.Debug("log cache hit", "path", path)
+	return e.v, true
+}
+
+// put caches v as decoded from path when it had stamp. The stamp is taken
+// before reading, so a log written to mid-read is read again next time.
+func (c *logCache) put(path string, stamp fileStamp, v interface{}) {
+	if isURL(path) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = cachedLog{stamp, v}
+}
+
+// loadMatrix returns the parsed matrix log at path. The returned value is
+// shared between callers and must be treated as read-only.
+func loadMatrix(path string) (*matrix, error) {
+	if v, ok := parsedLogs.get(path); ok {
+		if data, ok := v.(*matrix); ok {
+			return data, nil
+		}
+	}
+	stamp := stampOf(path)
+	var data *matrix
+	err := readLog(path, func(r io.Reader) error {
+		data = &matrix{}
+		return decodeMatrix(r, data)
+	})
+	if err != nil {
+		return nil, inFile(path, err)
+	}
+	if len(data.NodeMatrix) == 0 && len(data.ContentMatrix) == 0 {
+		return nil, inFile(path, &fileError{Field: "NodeMatrix, ContentMatrix", Message: "both are empty or missing, so there is nothing to chart"})
+	}
+	slog.Debug("parsed matrix log", "path", path, "nodes", len(data.NodeMatrix), "contents", len(data.ContentMatrix))
+	parsedLogs.put(path, stamp, data)
+	return data, nil
+}
+
+// loadMatrixPage returns the matrix the named page is rendered from: its log
+// in the logs directory, or the logs merged into it with -merge.
+func loadMatrixPage(page string) (*matrix, error) {
+	files, ok := cfg.Merges[page]
+	if !ok {
+		return loadMatrix(logPath(page))
+	}
+	merged := &matrix{
+		ContentMatrix: map[string]ContentMatrix{},
+		NodeMatrix:    map[string]DiscoveredNodeMatrix{},
+	}
+	for _, path := range files {
+		data, err := loadMatrix(path)
+		if err != nil {
+			return nil, err
+		}
+		mergeMatrix(merged, data, logPage(path)+"/")
+	}
+	return merged, nil
+}
+
+// mergeMatrix adds src to dst, prefixing its node and content keys so logs
+// from different devices never collide. A node seen from two devices stays
+// two nodes; a key that is still repeated, such as a log listed twice, gets
+// its connection history and discovery delays concatenated.
+func mergeMatrix(dst, src *matrix, prefix string) {
+	for k, v := range src.ContentMatrix {
+		dst.ContentMatrix[prefix+k] = v
+	}
+	for k, v := range src.NodeMatrix {
+		if prev, ok := dst.NodeMatrix[prefix+k]; ok {
+			v.ConnectionHistory = append(append([]ConnectionInfo{}, prev.ConnectionHistory...), v.ConnectionHistory...)
+			v.DiscoveryDelays = append(append([]int64{}, prev.DiscoveryDelays...), v.DiscoveryDelays...)
+		}
+		dst.NodeMatrix[prefix+k] = v
+	}
+	dst.TotalUptime += src.TotalUptime
+}
+
+// loadBatteryMeasurements returns the parsed battery log at path. The
+// returned value is shared between callers and must be treated as read-only.
+func loadBatteryMeasurements(path string) (*BatteryMeasurements, error) {
+	if v, ok := parsedLogs.get(path); ok {
+		if data, ok := v.(*BatteryMeasurements); ok {
+			return data, nil
+		}
+	}
+	stamp := stampOf(path)
+	var data *BatteryMeasurements
+	err := readLog(path, func(r io.Reader) error {
+		data = &BatteryMeasurements{}
+		if err := json.NewDecoder(r).Decode(data); err != nil {
+			return fieldError("", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, inFile(path, err)
+	}
+	if len(data.BatteryMeasurement) == 0 {
+		return nil, inFile(path, &fileError{Field: "BatteryMeasurement", Message: "empty or missing, so there is nothing to chart"})
+	}
+	data.BatteryMeasurement = dropMalformedMeasurements(path, data.BatteryMeasurement)
+	if len(data.BatteryMeasurement) == 0 {
+		return nil, inFile(path, &fileError{Field: "BatteryMeasurement", Message: "no measurement is usable"})
+	}
+	slog.Debug("parsed battery log", "path", path, "measurements", len(data.BatteryMeasurement))
+	parsedLogs.put(path, stamp, data)
+	return data, nil
+}
+
+// dropMalformedMeasurements returns measurements without the ones whose
+// battery consumption or transfer interval isn't a number, logging each one
+// dropped.
+func dropMalformedMeasurements(path string, measurements []Measurement) []Measurement {
+	kept := measurements[:0]
+	for i, m := range measurements {
+		problem := ""
+		if math.IsNaN(float64(m.BatteryConsumption)) {
+			problem = "battery consumption is not a number"
+		} else if _, err := strconv.ParseFloat(strings.TrimSpace(m.TransferInterval), 64); err != nil {
+			problem = fmt.Sprintf("transfer interval %q is not a number", m.TransferInterval)
+		}
+		if problem != "" {
+			reportError(&fileError{File: path, Field: fmt.Sprintf("BatteryMeasurement[%d]", i), Message: problem + ", skipping"})
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// readLog opens the log at path and decodes it with decode. A log that ends
+// early or is cut off mid-value may still be being written, so the read is
+// retried up to -read-retries times, waiting -read-retry-delay and then
+// twice as long each time. The last error is returned if none succeed.
+func readLog(path string, decode func(io.Reader) error) error {
+	delay := cfg.ReadRetryDelay
+	for attempt := 0; ; attempt++ {
+		n, err := decodeLog(path, decode)
+		if err == nil || attempt >= cfg.ReadRetries || !isTruncated(err, n) {
+			return err
+		}
+		slog.Warn("log looks cut off, retrying", "path", path, "err", err, "delay", delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// decodeLog decodes the log at path with decode, returning how many bytes
+// of it, decompressed, decode read.
+func decodeLog(path string, decode func(io.Reader) error) (int64, error) {
+	file, err := openLog(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	r := &countingReader{r: file}
+	err = decode(r)
+	return r.n, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// isTruncated reports whether err is what decoding a partly written log
+// of n bytes gives: the input ending early, or a syntax error at its very
+// end, where it was cut off. A syntax error before the end is a malformed
+// log that reading again won't fix.
+func isTruncated(err error, n int64) bool {
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		return se.Offset >= n
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// gzipLog is a gzip-compressed log along with the file it is read from.
+type gzipLog struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (g gzipLog) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// isURL reports whether a log path is an http or https URL.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// openLog opens the log at path, falling back to path.gz when there is no
+// uncompressed copy. A path that is an http(s) URL is fetched instead,
+// within -http-timeout. Gzip-compressed content is recognised by its magic
+// header whatever the file is called, and decompressed as it is read.
+func openLog(path string) (io.ReadCloser, error) {
+	var file io.ReadCloser
+	var err error
+	if isURL(path) {
+		file, err = fetchLog(path)
+	} else {
+		file, err = os.Open(path)
+		if os.IsNotExist(err) {
+			if gz, gzErr := os.Open(path + ".gz"); gzErr == nil {
+				file, err = gz, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(2)
+	if !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return struct {
+			io.Reader
+			io.Closer
+		}{br, file}, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipLog{zr, file}, nil
+}
+
+// fetchLog GETs the log at url. Anything but 200 OK is an error.
+func fetchLog(url string) (io.ReadCloser, error) {
+	client := &http.Client{Timeout: cfg.HTTPTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.New(resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// logPage returns the page name for a log given by path or URL: its file
+// name without the .log or .log.gz extension.
+func logPage(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 && isURL(path) {
+		path = path[:i]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".log")
+}
+
+// logKind tells what a log holds from its top-level keys without decoding
+// the rest: "matrix", "battery", or "" when it is neither.
+func logKind(path string) (string, error) {
+	file, err := openLog(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	dec := json.NewDecoder(file)
+	if err := expectDelim(dec, '{'); err != nil {
+		return "", err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch tok {
+		case "BatteryMeasurement":
+			return "battery", nil
+		case "NodeMatrix", "ContentMatrix":
+			return "matrix", nil
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// logFileName reports whether info is a log file, named <page>.log or
+// <page>.log.gz, and returns its page name.
+func logFileName(info os.FileInfo) (page string, ok bool) {
+	name := strings.TrimSuffix(info.Name(), ".gz")
+	if info.IsDir() || filepath.Ext(name) != ".log" {
+		return "", false
+	}
+	return strings.TrimSuffix(name, ".log"), true
+}
+
+// scanLogs sets matrixFiles and batteryMeasurementFiles to the logs found
+// in dir, by kind, in name order. Files that are neither kind, including
+// ones that aren't JSON at all, are skipped with a warning.
+func scanLogs(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	matrixFiles, batteryMeasurementFiles = nil, nil
+	for _, info := range infos {
+		page, ok := logFileName(info)
+		if !ok {
+			continue
+		}
+		if contains(matrixFiles, page) || contains(batteryMeasurementFiles, page) {
+			continue // both page.log and page.log.gz
+		}
+		kind, err := logKind(filepath.Join(dir, info.Name()))
+		if err != nil {
+			slog.Warn("unreadable log, skipping", "file", info.Name(), "err", err)
+			continue
+		}
+		switch kind {
+		case "matrix":
+			matrixFiles = append(matrixFiles, page)
+		case "battery":
+			batteryMeasurementFiles = append(batteryMeasurementFiles, page)
+		default:
+			slog.Warn("neither a matrix nor a battery log, skipping", "file", info.Name())
+		}
+	}
+	return nil
+}
+
+// decodeMatrix streams a matrix log from r. The node and content maps are
+// decoded one entry at a time so peak memory stays close to the size of the
+// parsed result instead of holding the raw document alongside it.
+func decodeMatrix(r io.Reader, data *matrix) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "ContentMatrix":
+			if data.ContentMatrix == nil {
+				data.ContentMatrix = map[string]ContentMatrix{}
+			}
+			err = decodeObject(dec, func(k string) error {
+				var v ContentMatrix
+				if err := dec.Decode(&v); err != nil {
+					return fieldError("ContentMatrix."+k, err)
+				}
+				data.ContentMatrix[k] = v
+				return nil
+			})
+		case "NodeMatrix":
+			if data.NodeMatrix == nil {
+				data.NodeMatrix = map[string]DiscoveredNodeMatrix{}
+			}
+			err = decodeObject(dec, func(k string) error {
+				var v DiscoveredNodeMatrix
+				if err := dec.Decode(&v); err != nil {
+					return fieldError("NodeMatrix."+k, err)
+				}
+				data.NodeMatrix[k] = v
+				return nil
+			})
+		case "TotalUptime":
+			err = dec.Decode(&data.TotalUptime)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return fieldError(key, err)
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeObject walks a JSON object, calling fn with each key while the
+// decoder is positioned at the corresponding value. A null object is
+// accepted and treated as empty.
+func decodeObject(dec *json.Decoder, fn func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("expected object, got %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := fn(tok.(string)); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReadLogRetriesTruncated reads a log cut off mid-write that is
+// complete by the second read.
+func TestReadLogRetriesTruncated(t *testing.T) {
+	useTestdata(t)
+	cfg.ReadRetries, cfg.ReadRetryDelay = 3, time.Millisecond
+	full, err := os.ReadFile(filepath.Join("testdata", "fixture_matrix.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "growing.log")
+	if err := os.WriteFile(path, full[:len(full)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	reads := 0
+	var data *matrix
+	err = readLog(path, func(r io.Reader) error {
+		reads++
+		data = &matrix{}
+		err := decodeMatrix(r, data)
+		if reads == 1 {
+			// The writer finishes the log after the first read.
+			if err := os.WriteFile(path, full, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reads != 2 || len(data.NodeMatrix) == 0 {
+		t.Errorf("read %d times with %d nodes, want the whole log on the second read", reads, len(data.NodeMatrix))
+	}
+}
+
+// TestReadLogMalformed expects a log broken before its end to fail at once
+// rather than be retried as if cut off.
+func TestReadLogMalformed(t *testing.T) {
+	useTestdata(t)
+	cfg.ReadRetries, cfg.ReadRetryDelay = 3, time.Hour
+	path := filepath.Join(t.TempDir(), "malformed.log")
+	if err := os.WriteFile(path, []byte(`{"NodeMatrix": {"a": {]}}, "TotalUptime": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reads := 0
+	err := readLog(path, func(r io.Reader) error {
+		reads++
+		return decodeMatrix(r, &matrix{})
+	})
+	if err == nil || reads != 1 {
+		t.Errorf("read %d times with error %v, want one failed read", reads, err)
+	}
+}
+
+// TestGzipLog renders a gzip-compressed copy of the matrix fixture, which
+// must chart the same as the plain log.
+func TestGzipLog(t *testing.T) {
+	useTestdata(t)
+	matrixFiles = []string{"fixture_matrix", "fixture_gzip"}
+	for _, page := range matrixFiles {
+		if _, err := renderMatrixPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plain := readOutput(t, "fixture_matrix.html")
+	gzipped := strings.ReplaceAll(readOutput(t, "fixture_gzip.html"), "fixture_gzip", "fixture_matrix")
+	if gzipped != plain {
+		t.Error("fixture_gzip.log.gz renders differently from fixture_matrix.log")
+	}
+
+	// Compressed content is recognised whatever the file is called.
+	gz, err := os.ReadFile(filepath.Join("testdata", "fixture_gzip.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "renamed.log")
+	if err := os.WriteFile(path, gz, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := loadMatrix(filepath.Join("testdata", "fixture_matrix.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadMatrix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("gzipped log named .log decodes differently from the plain log")
+	}
+}
+
+func TestIsTruncated(t *testing.T) {
+	log := `{"NodeMatrix": {"a": {"RSSI": -60}}, "TotalUptime": 1}`
+	for i := 0; i < len(log); i++ {
+		n, err := decodeLogString(log[:i])
+		if !isTruncated(err, n) {
+			t.Errorf("log cut off after %d bytes: %v isn't taken as truncated", i, err)
+		}
+	}
+	n, err := decodeLogString(strings.Replace(log, `"RSSI"`, `RSSI`, 1))
+	if isTruncated(err, n) {
+		t.Errorf("malformed log: %v taken as truncated", err)
+	}
+}
+
+// decodeLogString decodes a matrix log held in s as decodeLog would,
+// returning the bytes read and the error.
+func decodeLogString(s string) (int64, error) {
+	r := &countingReader{r: strings.NewReader(s)}
+	err := decodeMatrix(r, &matrix{})
+	return r.n, err
+}
+
+// largeMatrixLog returns the fixture matrix log with its nodes and contents
+// repeated n times.
+func largeMatrixLog(b *testing.B, n int) []byte {
+	f, err := os.Open(filepath.Join("testdata", "fixture_matrix.log"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	var fixture matrix
+	if err := decodeMatrix(f, &fixture); err != nil {
+		b.Fatal(err)
+	}
+	large := matrix{
+		ContentMatrix: map[string]ContentMatrix{},
+		NodeMatrix:    map[string]DiscoveredNodeMatrix{},
+		TotalUptime:   fixture.TotalUptime,
+	}
+	for i := 0; i < n; i++ {
+		for k, v := range fixture.ContentMatrix {
+			large.ContentMatrix[fmt.Sprintf("%s-%d", k, i)] = v
+		}
+		for k, v := range fixture.NodeMatrix {
+			large.NodeMatrix[fmt.Sprintf("%s-%d", k, i)] = v
+		}
+	}
+	log, err := json.Marshal(large)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return log
+}
+
+func BenchmarkDecodeMatrix(b *testing.B) {
+	log := largeMatrixLog(b, 5000)
+	b.SetBytes(int64(len(log)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := decodeMatrix(bytes.NewReader(log), &matrix{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUnmarshalMatrix reads the whole log before decoding it, as
+// loadMatrix did before decodeMatrix, for comparison.
+func BenchmarkUnmarshalMatrix(b *testing.B) {
+	log := largeMatrixLog(b, 5000)
+	b.SetBytes(int64(len(log)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		raw, err := io.ReadAll(bytes.NewReader(log))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var data matrix
+		if err := json.Unmarshal(raw, &data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestScanLogsSkipsOtherLogs scans the fixtures alongside a plain-text log
+// and a JSON log of neither kind, which must be skipped rather than stop
+// the scan.
+func TestScanLogsSkipsOtherLogs(t *testing.T) {
+	useTestdata(t)
+	dir := t.TempDir()
+	for _, name := range []string{"fixture_matrix.log", "fixture_battery.log"} {
+		b, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	others := map[string]string{
+		"app.log":   "plain text, not JSON\n",
+		"other.log": `{"Something": "else"}`,
+	}
+	for name, content := range others {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := scanLogs(dir); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"fixture_matrix"}; !reflect.DeepEqual(matrixFiles, want) {
+		t.Errorf("matrix pages %q, want %q", matrixFiles, want)
+	}
+	if want := []string{"fixture_battery"}; !reflect.DeepEqual(batteryMeasurementFiles, want) {
+		t.Errorf("battery pages %q, want %q", batteryMeasurementFiles, want)
+	}
+	if err := scanLogs(filepath.Join(dir, "missing")); err == nil {
+		t.Error("scanning a missing directory returned no error")
+	}
+}
+
+// TestLoadMatrixURLNotCached loads a log by URL twice with its content
+// changed in between, which the second load must see.
+func TestLoadMatrixURLNotCached(t *testing.T) {
+	useTestdata(t)
+	var mu sync.Mutex
+	body := `{"NodeMatrix": {"QmNodeA": {}}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	url := srv.URL + "/remote.log"
+
+	data, err := loadMatrix(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.NodeMatrix) != 1 {
+		t.Fatalf("first load has %d nodes, want 1", len(data.NodeMatrix))
+	}
+	mu.Lock()
+	body = `{"NodeMatrix": {"QmNodeA": {}, "QmNodeB": {}}}`
+	mu.Unlock()
+	data, err = loadMatrix(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.NodeMatrix) != 2 {
+		t.Errorf("second load has %d nodes, want the 2 the log now holds", len(data.NodeMatrix))
+	}
+}
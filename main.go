@@ -1,18 +1,21 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
-	"net/http"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/components"
 	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
 )
 
 type ContentMatrix struct {
@@ -58,58 +61,391 @@ type BatteryMeasurements struct {
 	BatteryMeasurement []Measurement `json:"BatteryMeasurement"`
 }
 type Measurement struct {
-	DataTransfer       string `json:"DataTransfer"`
-	TransferInterval   string `json:"TransferInterval"`
-	BatteryConsumption int    `json:"Battery Consumption"`
+	DataTransfer       string      `json:"DataTransfer"`
+	TransferInterval   string      `json:"TransferInterval"`
+	BatteryConsumption consumption `json:"Battery Consumption"`
+	Device             string      `json:"Device,omitempty"`
+	Firmware           string      `json:"Firmware,omitempty"`
 }
 
-var matrixFiles = []string{"zero_host_downloader", "zero_client_uploader", "five_host_downloader", "five_client_uploader"}
-var batteryMeasurementFiles = []string{"battery_measurements"}
+// consumption is a battery consumption in percent. Some apps log it as a
+// string, so both 12.5 and "12.5" decode; anything that isn't a number
+// decodes as NaN and is dropped when the log is loaded.
+type consumption float64
 
-func main() {
-	for _, v := range matrixFiles {
-		err := renderMatrixPage(v)
-		if err != nil {
-			log.Fatal("Page render failed ", err.Error())
+func (c *consumption) UnmarshalJSON(b []byte) error {
+	v, err := strconv.ParseFloat(strings.TrimSpace(strings.Trim(string(b), `"`)), 64)
+	if err != nil {
+		v = math.NaN()
+	}
+	*c = consumption(v)
+	return nil
+}
+
+// intervalSeconds returns the TransferInterval in seconds. Measurements
+// whose interval isn't a number are dropped when the log is loaded.
+func (m Measurement) intervalSeconds() float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(m.TransferInterval), 64)
+	return v
+}
+
+// matrixFiles and batteryMeasurementFiles name the pages rendered from the
+// logs directory, by kind. They are found by scanning it unless given with
+// -matrix-pages and -battery-pages.
+var matrixFiles, batteryMeasurementFiles []string
+
+// dataset is a matrix log charted as one of several, e.g. on the -compare
+// page. Name labels its series and captions; a lone dataset without a Name
+// keeps the chart's own labels.
+type dataset struct {
+	Name string
+	Data *matrix
+}
+
+// series names the series of the dataset in a chart that would call it
+// name on its own.
+func (s dataset) series(name string) string {
+	if s.Name == "" {
+		return name
+	}
+	return s.Name
+}
+
+// caption prefixes caption with the dataset's name, if it has one.
+func (s dataset) caption(caption string) string {
+	if s.Name == "" {
+		return caption
+	}
+	return s.Name + ": " + caption
+}
+
+// matrixChart names a chart built from a matrix log. The name is what the
+// chart is addressed by outside the page, e.g. in the JSON API.
+type matrixChart struct {
+	name  string
+	build func(*matrix) components.Charter
+}
+
+// nodeChart names a chart of a single node of a matrix log.
+type nodeChart struct {
+	name  string
+	build func(nodeID string, data *matrix) components.Charter
+}
+
+// compareChart names a chart that can plot several matrix logs side by side,
+// one series each.
+type compareChart struct {
+	name  string
+	build func([]dataset) components.Charter
+}
+
+// batteryChart names a chart built from a battery measurements log.
+type batteryChart struct {
+	name  string
+	build func(*BatteryMeasurements) components.Charter
+}
+
+// matrixCharts lists the charts of a matrix page in the order they render.
+var matrixCharts = []matrixChart{
+	{"overall-success", func(d *matrix) components.Charter { return overallSuccessGauge(d) }},
+	{"alive-status", func(d *matrix) components.Charter { return aliveStatusSummary(d) }},
+	{"ble-to-wifi", func(d *matrix) components.Charter { return bleToWifi([]dataset{{Data: d}}) }},
+	{"ble-to-ipfs", func(d *matrix) components.Charter { return bleToIpfs([]dataset{{Data: d}}) }},
+	{"ble-to-ipfs-percentiles", func(d *matrix) components.Charter { return bleToIpfsPercentiles([]dataset{{Data: d}}) }},
+	{"handshake-breakdown", func(d *matrix) components.Charter { return handshakeBreakdown(d) }},
+	{"discovery-delay-trend", func(d *matrix) components.Charter { return discoveryDelayTrend(d) }},
+	{"discovery-delay-boxplot", func(d *matrix) components.Charter { return discoveryDelayBoxplot(d) }},
+	{"network-health", func(d *matrix) components.Charter { return networkHealth(d) }},
+	{"rssi-over-time", func(d *matrix) components.Charter { return rssiOverTime(d) }},
+	{"rssi-speed", func(d *matrix) components.Charter { return rssiSpeed(d) }},
+	{"speed-by-rssi", func(d *matrix) components.Charter { return speedByRSSI(d) }},
+	{"rssi-speed-correlation", func(d *matrix) components.Charter { return rssiSpeedCorrelation(d) }},
+	{"rssi-vs-discovery-delay", func(d *matrix) components.Charter { return rssiVsDiscoveryDelay(d) }},
+	{"frequency-usage", func(d *matrix) components.Charter { return frequencyUsage(d) }},
+	{"connection-heatmap", func(d *matrix) components.Charter { return connectionHeatmap(d) }},
+	{"speed-by-band", func(d *matrix) components.Charter { return speedByBand(d) }},
+	{"download-speed", func(d *matrix) components.Charter { return downloadSpeed([]dataset{{Data: d}}) }},
+	{"download-throughput", func(d *matrix) components.Charter { return downloadThroughput(d) }},
+	{"download-speed-histogram", func(d *matrix) components.Charter { return downloadSpeedHistogram(d) }},
+	{"content-size", func(d *matrix) components.Charter { return contentSize(d) }},
+	{"size-vs-speed", func(d *matrix) components.Charter { return sizeVsSpeed(d) }},
+	{"slowest-downloads", func(d *matrix) components.Charter { return slowestDownloads(d) }},
+	{"provider-counts", func(d *matrix) components.Charter { return providerCounts(d) }},
+	{"provider-contribution", func(d *matrix) components.Charter { return providerContribution(d) }},
+	{"connection-success-rate", func(d *matrix) components.Charter { return connectionSuccessRateByNode(d) }},
+	{"flakiest-nodes", func(d *matrix) components.Charter { return flakiestNodes(d) }},
+	{"last-session-duration", func(d *matrix) components.Charter { return lastSessionDuration(d) }},
+	{"connection-age", func(d *matrix) components.Charter { return connectionAge(d) }},
+	{"connection-duration", func(d *matrix) components.Charter { return connectionDuration(d) }},
+	{"download-completion", func(d *matrix) components.Charter { return downloadCompletion(d) }},
+}
+
+// nodeCharts lists the charts that show one node of a matrix log, in the
+// order they render on its node page. The JSON API serves them with the node
+// given as ?node=.
+var nodeCharts = []nodeChart{
+	{"connection-timeline", func(id string, d *matrix) components.Charter { return connectionTimeline(id, d) }},
+	{"node-rssi", func(id string, d *matrix) components.Charter { return nodeRSSI(id, d) }},
+	{"node-discovery-delays", func(id string, d *matrix) components.Charter { return nodeDiscoveryDelays(id, d) }},
+	{"node-connection-outcomes", func(id string, d *matrix) components.Charter { return nodeConnectionOutcomes(id, d) }},
+}
+
+// compareCharts lists the charts of the -compare page in the order they
+// render. Each also renders on matrix pages with just that page's log.
+var compareCharts = []compareChart{
+	{"ble-to-wifi", func(s []dataset) components.Charter { return bleToWifi(s) }},
+	{"ble-to-ipfs", func(s []dataset) components.Charter { return bleToIpfs(s) }},
+	{"ble-to-ipfs-percentiles", func(s []dataset) components.Charter { return bleToIpfsPercentiles(s) }},
+	{"download-speed", func(s []dataset) components.Charter { return downloadSpeed(s) }},
+}
+
+// batteryCharts lists the charts of a battery page in the order they render.
+var batteryCharts = []batteryChart{
+	{"battery-consumption", func(d *BatteryMeasurements) components.Charter { return transferIntervalToBatteryPercentage(d) }},
+	{"battery-consumption-datahop", func(d *BatteryMeasurements) components.Charter {
+		return transferIntervalToBatteryPercentageOnlyDatahop(d)
+	}},
+	{"battery-consumption-rate", func(d *BatteryMeasurements) components.Charter { return batteryConsumptionRate(d) }},
+	{"battery-efficiency", func(d *BatteryMeasurements) components.Charter { return batteryEfficiency(d) }},
+	{"battery-runtime", func(d *BatteryMeasurements) components.Charter { return batteryRuntime(d) }},
+	{"firmware-consumption", func(d *BatteryMeasurements) components.Charter { return firmwareConsumption(d) }},
+}
+
+// isChartName reports whether name is the name of a matrix, node or battery
+// chart.
+func isChartName(name string) bool {
+	for _, c := range matrixCharts {
+		if c.name == name {
+			return true
+		}
+	}
+	for _, c := range nodeCharts {
+		if c.name == name {
+			return true
+		}
+	}
+	for _, c := range batteryCharts {
+		if c.name == name {
+			return true
 		}
 	}
+	return false
+}
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(args); err != nil {
+		reportError(err)
+		os.Exit(1)
+	}
+}
 
+// Titles of the rendered pages, also used to label them in the index.
+const (
+	matrixPageTitle  = "Datahop Matrix Charts"
+	batteryPageTitle = "Datahop Battery Measurement Charts"
+	runsPageTitle    = "Datahop Cross-Run Trend"
+	comparePageTitle = "Datahop Comparison"
+	indexPageTitle   = "Datahop Charts"
+)
+
+// renderAll renders every configured page into the output directory,
+// followed by an index linking to them. Up to -jobs pages render at once;
+// each writes its own files. A page that fails to render is reported and
+// left out of the index so the others still render; the error returned
+// counts the failures.
+func renderAll() error {
+	if err := os.MkdirAll(cfg.OutDir, 0755); err != nil {
+		return err
+	}
+	// Each render fills in its own result, so the pages can render in any
+	// order and still be listed in the order they're configured.
+	type result struct {
+		entry   indexEntry
+		summary *pageSummary
+		err     error
+	}
+	var renders []func(*result)
+	for _, v := range matrixFiles {
+		v := v
+		renders = append(renders, func(r *result) {
+			r.entry = indexEntry{File: v + ".html", Name: v, Title: pageTitle(v, matrixPageTitle), CSV: v + ".csv"}
+			data, err := renderMatrixPage(v)
+			if r.err = err; err != nil {
+				return
+			}
+			for _, id := range nodeIDs(data) {
+				r.entry.Nodes = append(r.entry.Nodes, indexEntry{File: filepath.ToSlash(nodePageFile(v, id)), Name: id})
+			}
+			s := summarize(v, data)
+			r.summary = &s
+		})
+	}
 	for _, v := range batteryMeasurementFiles {
-		err := renderBatteryMeasurementPage(v)
-		if err != nil {
-			log.Fatal("Page render failed ", err.Error())
-		}
+		v := v
+		renders = append(renders, func(r *result) {
+			r.entry = indexEntry{File: v + ".html", Name: v, Title: pageTitle(v, batteryPageTitle)}
+			r.err = renderBatteryMeasurementPage(v)
+		})
+	}
+	if cfg.RunsDir != "" && cfg.Only == "" {
+		renders = append(renders, func(r *result) {
+			r.entry = indexEntry{File: "cross_run_trend.html", Name: "cross_run_trend", Title: runsPageTitle}
+			r.err = renderRunTrendPage(cfg.RunsDir, cfg.RunsMetric)
+		})
+	}
+	if len(cfg.Compare) > 0 && cfg.Only == "" {
+		renders = append(renders, func(r *result) {
+			r.entry = indexEntry{File: comparePage + ".html", Name: comparePage, Title: comparePageTitle}
+			r.err = renderComparePage(cfg.Compare)
+		})
+	}
+	results := make([]result, len(renders))
+	jobs := make(chan struct{}, cfg.Jobs)
+	var wg sync.WaitGroup
+	for i, render := range renders {
+		wg.Add(1)
+		jobs <- struct{}{}
+		go func(render func(*result), r *result) {
+			defer func() { <-jobs; wg.Done() }()
+			render(r)
+		}(render, &results[i])
 	}
+	wg.Wait()
 
-	fs := http.FileServer(http.Dir("html"))
-	log.Println("running server at http://localhost:8089")
-	http.ListenAndServe("localhost:8089", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL)
-		fs.ServeHTTP(w, r)
-	}))
+	failed := 0
+	var index []indexEntry
+	var summaries []pageSummary
+	for _, r := range results {
+		if r.err != nil {
+			reportError(fmt.Errorf("rendering %s: %w", r.entry.Name, r.err))
+			failed++
+			continue
+		}
+		slog.Info("rendered page", "page", r.entry.Name)
+		index = append(index, r.entry)
+		if r.summary != nil {
+			summaries = append(summaries, *r.summary)
+		}
+	}
+	// With -only the index and summary would list just the one page, so
+	// the ones from the last full render are kept.
+	if cfg.Only == "" {
+		if err := renderIndex(index, summaries); err != nil {
+			return err
+		}
+		if err := writeSummary(summaries); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pages failed to render", failed, len(results))
+	}
+	return nil
 }
 
 func renderBatteryMeasurementPage(pageName string) error {
-	file, err := ioutil.ReadFile(fmt.Sprintf("logs/%s.log", pageName))
+	data, err := loadBatteryMeasurements(logPath(pageName))
 	if err != nil {
-		log.Fatal("matrix file missing ", err.Error())
+		return err
 	}
-	data := &BatteryMeasurements{}
-	err = json.Unmarshal(file, data)
+	page := components.NewPage()
+	rng := newRand()
+	for _, c := range batteryCharts {
+		if !showsChart(pageName, c.name) {
+			continue
+		}
+		chart := c.build(data)
+		markEmpty(chart)
+		setChartID(chart, rng)
+		setTheme(chart)
+		setSize(chart)
+		hideSeries(c.name, chart)
+		colorSeries(chart)
+		if err := applyTitleTemplates(c.name, chart); err != nil {
+			return err
+		}
+		if err := snapshotChart(pageName, c.name, chart); err != nil {
+			return err
+		}
+		page.AddCharts(chart)
+	}
+	page.PageTitle = pageTitle(pageName, batteryPageTitle)
+	if err := live.publish(pageName, page); err != nil {
+		return err
+	}
+	footer, err := rawDataFooter(data)
 	if err != nil {
-		log.Fatal("matrix file missing ", err.Error())
+		return err
 	}
-	page := components.NewPage()
-	page.AddCharts(
-		transferIntervalToBatteryPercentage(data),
-		transferIntervalToBatteryPercentageOnlyDatahop(data),
-	)
-	page.PageTitle = "Datahop Battery Measurement Charts"
-	f, err := os.Create(fmt.Sprintf("html/%s.html", pageName))
+	footer += liveScript(pageName) + gridStyle() + tabsScript()
+	f, err := os.Create(outPath(pageName + ".html"))
 	if err != nil {
-		log.Fatal("unable to create file ", err.Error())
+		return err
+	}
+	defer f.Close()
+	return renderPage(page, f, "", footer)
+}
+
+// idleConsumption is the battery, in percent, an idle device consumed over
+// idleHours. Measurements of other lengths subtract it scaled to theirs.
+const (
+	idleConsumption = 2
+	idleHours       = 3
+)
+
+// consumptionByInterval sets up bar with a series per transfer size plotting
+// battery consumption, less baseline and divided by per, against transfer
+// interval. Intervals and sizes are taken from the measurements and sorted
+// numerically. It returns how many measurements were plotted.
+func consumptionByInterval(bar *charts.Bar, data *BatteryMeasurements, baseline, per float64) int {
+	type key struct {
+		transfer string
+		interval float64
+	}
+	values, seen := map[key]float64{}, map[float64]bool{}
+	transfers, intervals := []string{}, []float64{}
+	for _, v := range data.BatteryMeasurement {
+		if !contains(transfers, v.DataTransfer) {
+			transfers = append(transfers, v.DataTransfer)
+		}
+		interval := v.intervalSeconds()
+		if !seen[interval] {
+			seen[interval] = true
+			intervals = append(intervals, interval)
+		}
+		values[key{v.DataTransfer, interval}] = math.Round((float64(v.BatteryConsumption)-baseline)/per*100) / 100
+	}
+	sortNumeric(transfers)
+	sort.Float64s(intervals)
+	xAxis := make([]string, 0, len(intervals))
+	for _, i := range intervals {
+		xAxis = append(xAxis, strconv.FormatFloat(i, 'f', -1, 64)+"s")
+	}
+	bar.SetXAxis(xAxis)
+	used := 0
+	for _, t := range transfers {
+		items := make([]opts.BarData, 0, len(intervals))
+		for _, i := range intervals {
+			v, ok := values[key{t, i}]
+			if !ok {
+				items = append(items, opts.BarData{Value: "-"})
+				continue
+			}
+			items = append(items, opts.BarData{Value: v})
+			used++
+		}
+		bar.AddSeries(t+"Mb", items)
 	}
-	return page.Render(io.MultiWriter(f))
+	return used
 }
 
 func transferIntervalToBatteryPercentage(data *BatteryMeasurements) *charts.Bar {
@@ -118,32 +454,19 @@ func transferIntervalToBatteryPercentage(data *BatteryMeasurements) *charts.Bar
 	// set some global options like Title/Legend/ToolTip or anything else
 	bar.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
-			Title: "Battery Consumption of device after 3 hours of transfer",
+			Title: fmt.Sprintf("Battery Consumption of device after %g hours of transfer", cfg.BatteryHours),
 		}),
-		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
 		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
 	)
-	tenMbItems := make([]opts.BarData, 0)
-	hundredMbItems := make([]opts.BarData, 0)
-	for _, v := range data.BatteryMeasurement {
-		if v.DataTransfer == "10" {
-			tenMbItems = append(tenMbItems, opts.BarData{Value: v.BatteryConsumption})
-			continue
-		}
-		if v.DataTransfer == "100" {
-			hundredMbItems = append(hundredMbItems, opts.BarData{Value: v.BatteryConsumption})
-		}
-	}
-	// Put data into instance
-	bar.SetXAxis([]string{"40s", "120s"}).
-		AddSeries("10Mb", tenMbItems).
-		AddSeries("100Mb", hundredMbItems).
-		SetSeriesOptions(
-			charts.WithLabelOpts(opts.Label{
-				Show:     true,
-				Position: "insideTop",
-			}),
-		)
+	used := consumptionByInterval(bar, data, 0, 1)
+	addCaption(&bar.Title, completeness(used, len(data.BatteryMeasurement), "measurements"))
+	bar.SetSeriesOptions(
+		charts.WithLabelOpts(opts.Label{
+			Show:     true,
+			Position: "insideTop",
+		}),
+	)
 
 	return bar
 }
@@ -154,63 +477,356 @@ func transferIntervalToBatteryPercentageOnlyDatahop(data *BatteryMeasurements) *
 	// set some global options like Title/Legend/ToolTip or anything else
 	bar.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
-			Title:    "Battery Consumption by datahop demo app after 3 hours of transfer",
-			Subtitle: "Idle Device consumed 2% after 3 hours",
+			Title:    fmt.Sprintf("Battery Consumption by datahop demo app after %g hours of transfer", cfg.BatteryHours),
+			Subtitle: fmt.Sprintf("Idle Device consumed %d%% after %d hours", idleConsumption, idleHours),
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	used := consumptionByInterval(bar, data, idleConsumption*cfg.BatteryHours/idleHours, 1)
+	addCaption(&bar.Title, completeness(used, len(data.BatteryMeasurement), "measurements"))
+	bar.SetSeriesOptions(
+		charts.WithLabelOpts(opts.Label{
+			Show:     true,
+			Position: "insideTop",
+		}),
+	)
+
+	return bar
+}
+
+// batteryConsumptionRate plots battery consumption per hour of transfer
+// against transfer interval, so measurements that ran for different
+// -battery-hours compare directly.
+func batteryConsumptionRate(data *BatteryMeasurements) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Battery consumption per hour of transfer",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "% per hour",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	used := consumptionByInterval(bar, data, 0, cfg.BatteryHours)
+	addCaption(&bar.Title, completeness(used, len(data.BatteryMeasurement), "measurements"))
+	return bar
+}
+
+// batteryEfficiency ranks devices by battery consumed per MB transferred,
+// most efficient first. Measurements without a Device are grouped as
+// "default".
+func batteryEfficiency(data *BatteryMeasurements) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Battery consumption per MB by device",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "% per MB",
 		}),
-		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
 		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
 	)
-	tenMbItems := make([]opts.BarData, 0)
-	hundredMbItems := make([]opts.BarData, 0)
+	consumed, transferred := map[string]float64{}, map[string]float64{}
+	used := 0
 	for _, v := range data.BatteryMeasurement {
-		if v.DataTransfer == "10" {
-			tenMbItems = append(tenMbItems, opts.BarData{Value: v.BatteryConsumption - 2})
+		mb, err := strconv.ParseFloat(v.DataTransfer, 64)
+		if err != nil || mb <= 0 {
 			continue
 		}
-		if v.DataTransfer == "100" {
-			hundredMbItems = append(hundredMbItems, opts.BarData{Value: v.BatteryConsumption - 2})
+		device := v.Device
+		if device == "" {
+			device = "default"
 		}
+		consumed[device] += float64(v.BatteryConsumption)
+		transferred[device] += mb
+		used++
 	}
-	// Put data into instance
-	bar.SetXAxis([]string{"40s", "120s"}).
-		AddSeries("10Mb", tenMbItems).
-		AddSeries("100Mb", hundredMbItems).
-		SetSeriesOptions(
-			charts.WithLabelOpts(opts.Label{
-				Show:     true,
-				Position: "insideTop",
-			}),
-		)
+	devices := make([]string, 0, len(consumed))
+	for d := range consumed {
+		devices = append(devices, d)
+	}
+	perMB := func(d string) float64 { return consumed[d] / transferred[d] }
+	sort.Slice(devices, func(i, j int) bool {
+		if perMB(devices[i]) != perMB(devices[j]) {
+			return perMB(devices[i]) < perMB(devices[j])
+		}
+		return devices[i] < devices[j]
+	})
+	items := make([]opts.BarData, 0, len(devices))
+	for _, d := range devices {
+		items = append(items, opts.BarData{Value: math.Round(perMB(d)*1000) / 1000})
+	}
+	addCaption(&bar.Title, completeness(used, len(data.BatteryMeasurement), "measurements"))
+	bar.SetXAxis(devices).AddSeries("Consumption per MB", items)
+	return bar
+}
 
+// firmwareConsumption compares the mean battery consumption for each
+// transfer size across firmware versions, which is where power regressions
+// between releases show up. Measurements without a Firmware are grouped as
+// "unknown".
+func firmwareConsumption(data *BatteryMeasurements) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Battery consumption by firmware version",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "%",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	type key struct{ firmware, transfer string }
+	sums, counts := map[key]float64{}, map[key]int{}
+	firmwares, transfers := []string{}, []string{}
+	versioned := 0
+	for _, v := range data.BatteryMeasurement {
+		firmware := v.Firmware
+		if firmware == "" {
+			firmware = "unknown"
+		} else {
+			versioned++
+		}
+		k := key{firmware, v.DataTransfer}
+		if !contains(firmwares, firmware) {
+			firmwares = append(firmwares, firmware)
+		}
+		if !contains(transfers, v.DataTransfer) {
+			transfers = append(transfers, v.DataTransfer)
+		}
+		sums[k] += float64(v.BatteryConsumption)
+		counts[k]++
+	}
+	sort.Strings(firmwares)
+	sortNumeric(transfers)
+	xAxis := make([]string, 0, len(transfers))
+	for _, t := range transfers {
+		xAxis = append(xAxis, t+"Mb")
+	}
+	bar.SetXAxis(xAxis)
+	for _, f := range firmwares {
+		items := make([]opts.BarData, 0, len(transfers))
+		for _, t := range transfers {
+			k := key{f, t}
+			if counts[k] == 0 {
+				items = append(items, opts.BarData{Value: "-"})
+				continue
+			}
+			items = append(items, opts.BarData{Value: math.Round(sums[k]/float64(counts[k])*10) / 10})
+		}
+		bar.AddSeries(f, items)
+	}
+	addCaption(&bar.Title, completeness(versioned, len(data.BatteryMeasurement), "measurements with a firmware version"))
 	return bar
 }
 
-func renderMatrixPage(pageName string) error {
-	file, err := ioutil.ReadFile(fmt.Sprintf("logs/%s.log", pageName))
+// maxRuntimeHours caps the projected battery runtime; beyond it the
+// projection says more about measurement noise than the device.
+const maxRuntimeHours = 48
+
+// batteryRuntime projects how many hours of continuous transfer a device
+// starting at -battery-start percent can sustain. It assumes the heaviest
+// measured workload, so the estimate errs on the short side.
+func batteryRuntime(data *BatteryMeasurements) *charts.Gauge {
+	gauge := charts.NewGauge()
+	gauge.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Projected hours of transfer until empty",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartGauge)),
+	)
+	var worst *Measurement
+	for i, v := range data.BatteryMeasurement {
+		if v.BatteryConsumption > 0 && (worst == nil || v.BatteryConsumption > worst.BatteryConsumption) {
+			worst = &data.BatteryMeasurement[i]
+		}
+	}
+	hours := 0.0
+	if worst != nil {
+		perHour := float64(worst.BatteryConsumption) / cfg.BatteryHours
+		hours = math.Min(cfg.BatteryStart/perHour, maxRuntimeHours)
+		addCaption(&gauge.Title, fmt.Sprintf("assuming %sMB every %ss from %.0f%% charge", worst.DataTransfer, worst.TransferInterval, cfg.BatteryStart))
+	} else {
+		addCaption(&gauge.Title, "no measurement with a positive consumption")
+	}
+	gauge.AddSeries("Runtime", []opts.GaugeData{{Name: "hours", Value: math.Round(hours*10) / 10}})
+	// go-echarts has no gauge range option, so widen it once the chart is up.
+	gauge.AddJSFuncs(fmt.Sprintf("goecharts_%s.setOption({series: [{max: %d, detail: {formatter: '{value} h'}}]});",
+		gauge.ChartID, maxRuntimeHours))
+	return gauge
+}
+
+func renderMatrixPage(pageName string) (*matrix, error) {
+	data, err := loadMatrixPage(pageName)
+	if err != nil {
+		return nil, err
+	}
+	// The footer shows the log as parsed, before any records are dropped.
+	footer, err := rawDataFooter(data)
 	if err != nil {
-		log.Fatal("matrix file missing ", err.Error())
+		return nil, err
+	}
+	report := &qualityReport{Page: pageName, Issues: []qualityIssue{}}
+	checkMatrix(data, report)
+	if len(report.Issues) > 0 {
+		slog.Warn("data-quality issues", "page", pageName, "issues", len(report.Issues))
+	}
+	if cfg.DropNonMonotonic {
+		data = dropNonMonotonic(data)
+	}
+	if err := report.write(outPath(pageName + ".quality.json")); err != nil {
+		return nil, err
+	}
+	if err := renderSeriesCSV(pageName, data); err != nil {
+		return nil, err
+	}
+	page := components.NewPage()
+	rng := newRand()
+	for _, c := range matrixCharts {
+		if !showsChart(pageName, c.name) {
+			continue
+		}
+		chart := c.build(data)
+		markEmpty(chart)
+		setChartID(chart, rng)
+		setTheme(chart)
+		setSize(chart)
+		hideSeries(c.name, chart)
+		colorSeries(chart)
+		if err := applyTitleTemplates(c.name, chart); err != nil {
+			return nil, err
+		}
+		if err := snapshotChart(pageName, c.name, chart); err != nil {
+			return nil, err
+		}
+		page.AddCharts(chart)
 	}
-	data := &matrix{}
-	err = json.Unmarshal(file, data)
+	page.PageTitle = pageTitle(pageName, matrixPageTitle)
+	if err := live.publish(pageName, page); err != nil {
+		return nil, err
+	}
+	footer += liveScript(pageName) + gridStyle() + tabsScript()
+	f, err := os.Create(outPath(pageName + ".html"))
 	if err != nil {
-		log.Fatal("matrix file missing ", err.Error())
+		return nil, err
+	}
+	defer f.Close()
+	if err := renderPage(page, f, summaryCard(data), footer); err != nil {
+		return nil, err
 	}
+	for _, id := range nodeIDs(data) {
+		if err := renderNodePage(pageName, id, data); err != nil {
+			return nil, fmt.Errorf("node %s: %w", id, err)
+		}
+	}
+	return data, nil
+}
+
+// nodePageFile is where the page of a node of a matrix page is written,
+// relative to the output directory. Node pages get a directory per matrix
+// page since the same node can turn up in several logs.
+func nodePageFile(pageName, nodeID string) string {
+	return filepath.Join(pageName, "node_"+fileSafe(nodeID)+".html")
+}
+
+// renderNodePage writes the node charts of one node of a matrix page to its
+// nodePageFile.
+func renderNodePage(pageName, nodeID string, data *matrix) error {
 	page := components.NewPage()
-	page.AddCharts(
-		bleToWifi(data),
-		bleToIpfs(data),
-		rssiSpeed(data),
-		downloadSpeed(data),
-	)
-	page.PageTitle = "Datahop Matrix Charts"
-	f, err := os.Create(fmt.Sprintf("html/%s.html", pageName))
+	rng := newRand()
+	for _, c := range nodeCharts {
+		chart := c.build(nodeID, data)
+		markEmpty(chart)
+		setChartID(chart, rng)
+		setTheme(chart)
+		setSize(chart)
+		hideSeries(c.name, chart)
+		colorSeries(chart)
+		if err := applyTitleTemplates(c.name, chart); err != nil {
+			return err
+		}
+		page.AddCharts(chart)
+	}
+	page.PageTitle = fmt.Sprintf("%s: node %s", pageTitle(pageName, matrixPageTitle), nodeID)
+	if err := os.MkdirAll(outPath(pageName), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(outPath(nodePageFile(pageName, nodeID)))
 	if err != nil {
-		log.Fatal("unable to create file ", err.Error())
+		return err
+	}
+	defer f.Close()
+	return renderPage(page, f, "", gridStyle()+tabsScript())
+}
+
+// overallSuccessGauge shows the share of connection attempts that succeeded
+// across all nodes.
+func overallSuccessGauge(data *matrix) *charts.Gauge {
+	gauge := charts.NewGauge()
+	gauge.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Overall connection success rate",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartGauge)),
+	)
+	attempts := 0
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		attempts += v.ConnectionSuccessCount + v.ConnectionFailureCount
+	}
+	if attempts == 0 {
+		addCaption(&gauge.Title, "no connection attempts recorded")
+	} else {
+		addCaption(&gauge.Title, fmt.Sprintf("n=%d connection attempts", attempts))
+	}
+	gauge.AddSeries("Success rate", []opts.GaugeData{{Name: "%", Value: math.Round(connectionSuccessRate(data)*10) / 10}})
+	return gauge
+}
+
+// wifiDelays returns the seconds from BLE discovery to Wifi connection of
+// every connection with both timestamps, node by node.
+func wifiDelays(data *matrix) []float64 {
+	values := []float64{}
+	for _, id := range nodeIDs(data) {
+		for _, k := range data.NodeMatrix[id].ConnectionHistory {
+			if k.BLEDiscoveredAt != 0 && k.WifiConnectedAt != 0 {
+				values = append(values, durationSeconds(k.BLEDiscoveredAt, k.WifiConnectedAt))
+			}
+		}
+	}
+	return values
+}
+
+// discoveryDelays returns every node's discovery delays in seconds, node by
+// node.
+func discoveryDelays(data *matrix) []float64 {
+	values := []float64{}
+	for _, id := range nodeIDs(data) {
+		for _, d := range data.NodeMatrix[id].DiscoveryDelays {
+			values = append(values, float64(d))
+		}
+	}
+	return values
+}
+
+// downloadSpeeds returns the AvgSpeed of every content item that
+// downloaded, in content order. No speed means the download failed.
+func downloadSpeeds(data *matrix) []float64 {
+	values := []float64{}
+	for _, cid := range contentIDs(data) {
+		if v := data.ContentMatrix[cid]; v.AvgSpeed > 0 {
+			values = append(values, float64(v.AvgSpeed))
+		}
 	}
-	return page.Render(io.MultiWriter(f))
+	return values
 }
 
-func bleToWifi(data *matrix) *charts.Line {
+func bleToWifi(sets []dataset) *charts.Line {
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithTitleOpts(
@@ -228,34 +844,39 @@ func bleToWifi(data *matrix) *charts.Line {
 				Name: "Seconds",
 			},
 		),
-		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
 		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
 	)
-	xAxis := []int{}
-	yAxis := make([]opts.LineData, 0)
-	for _, v := range data.NodeMatrix {
-		for _, k := range v.ConnectionHistory {
-			if k.WifiConnectedAt != 0 {
-				xAxis = append(xAxis, len(xAxis))
-				yAxis = append(yAxis, opts.LineData{Value: k.WifiConnectedAt - k.BLEDiscoveredAt})
-			}
+	longest := 0
+	for _, set := range sets {
+		values := wifiDelays(set.Data)
+		yAxis := make([]opts.LineData, 0, len(values))
+		for _, d := range values {
+			yAxis = append(yAxis, opts.LineData{Value: d})
 		}
+		available := 0
+		for _, id := range nodeIDs(set.Data) {
+			available += len(set.Data.NodeMatrix[id].ConnectionHistory)
+		}
+		addCaption(&line.Title, set.caption(completeness(len(yAxis), available, "connections with BLE and Wifi timestamps")))
 
-	}
-
-	line.SetXAxis(xAxis).AddSeries("BLE to Wifi", yAxis).
-		SetSeriesOptions(
-			charts.WithAreaStyleOpts(opts.AreaStyle{
-				Opacity: 0.2,
-			}),
+		line.AddSeries(set.series("BLE to Wifi"), thinLine(yAxis, values),
+			withAreaFill(),
+			withPercentileLines(values),
+			withAnnotations(),
 			charts.WithLineChartOpts(opts.LineChart{
 				Smooth: true,
 			}),
 		)
+		if len(yAxis) > longest {
+			longest = len(yAxis)
+		}
+	}
+	line.SetXAxis(countIndices(longest))
 	return line
 }
 
-func bleToIpfs(data *matrix) *charts.Line {
+func bleToIpfs(sets []dataset) *charts.Line {
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithTitleOpts(
@@ -273,92 +894,1582 @@ func bleToIpfs(data *matrix) *charts.Line {
 				Name: "Seconds",
 			},
 		),
-		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
 		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
 	)
-	xAxis := []int{}
-	yAxis := make([]opts.LineData, 0)
-	for _, v := range data.NodeMatrix {
-		for _, k := range v.DiscoveryDelays {
-			xAxis = append(xAxis, len(xAxis))
-			yAxis = append(yAxis, opts.LineData{Value: k})
+	longest := 0
+	for _, set := range sets {
+		values := discoveryDelays(set.Data)
+		yAxis := make([]opts.LineData, 0, len(values))
+		for _, d := range values {
+			yAxis = append(yAxis, opts.LineData{Value: d})
 		}
-	}
+		delayed := 0
+		for _, id := range nodeIDs(set.Data) {
+			if len(set.Data.NodeMatrix[id].DiscoveryDelays) > 0 {
+				delayed++
+			}
+		}
+		addCaption(&line.Title, set.caption(fmt.Sprintf("%d discovery delays from %s", len(values),
+			completeness(delayed, len(nodeIDs(set.Data)), "nodes"))))
 
-	line.SetXAxis(xAxis).AddSeries("BLE to IPFS", yAxis).
-		SetSeriesOptions(
-			charts.WithAreaStyleOpts(opts.AreaStyle{
-				Opacity: 0.2,
-			}),
+		line.AddSeries(set.series("BLE to IPFS"), thinLine(yAxis, values),
+			withAreaFill(),
+			withPercentileLines(values),
+			withAnnotations(),
 			charts.WithLineChartOpts(opts.LineChart{
 				Smooth: true,
 			}),
 		)
+		if cfg.MovingAverage > 0 {
+			averages := movingAverage(values, cfg.MovingAverage)
+			items := make([]opts.LineData, 0, len(averages))
+			for _, v := range averages {
+				items = append(items, opts.LineData{Value: math.Round(v*10) / 10})
+			}
+			line.AddSeries(set.caption(fmt.Sprintf("%d-point moving average", cfg.MovingAverage)), thinLine(items, averages),
+				charts.WithLineChartOpts(opts.LineChart{Smooth: true}),
+			)
+		}
+		if len(yAxis) > longest {
+			longest = len(yAxis)
+		}
+	}
+	line.SetXAxis(countIndices(longest))
 	return line
 }
 
-var (
-	parallelAxisList = []opts.ParallelAxis{
-		{Dim: 0, Name: "RSSI"},
-		{Dim: 1, Name: "Speed"},
+// delayPercentiles are the percentiles of the BLE to IPFS delay summarised
+// next to the raw delays.
+var delayPercentiles = []float64{50, 90, 99}
+
+// bleToIpfsPercentiles summarises the BLE to IPFS delays of every node as
+// their delayPercentiles. With no delays every percentile is missing.
+func bleToIpfsPercentiles(sets []dataset) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "BLE discovery to IPFS connection percentiles",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Seconds",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	xAxis := make([]string, 0, len(delayPercentiles))
+	for _, p := range delayPercentiles {
+		xAxis = append(xAxis, fmt.Sprintf("p%g", p))
 	}
-)
+	bar.SetXAxis(xAxis)
+	for _, set := range sets {
+		values := discoveryDelays(set.Data)
+		items := make([]opts.BarData, 0, len(delayPercentiles))
+		for _, p := range delayPercentiles {
+			if len(values) == 0 {
+				items = append(items, opts.BarData{Value: "-"})
+				continue
+			}
+			items = append(items, opts.BarData{Value: math.Round(percentile(values, p)*10) / 10})
+		}
+		addCaption(&bar.Title, set.caption(fmt.Sprintf("n=%d discovery delays", len(values))))
+		bar.AddSeries(set.series("BLE to IPFS"), items)
+	}
+	return bar
+}
 
-func rssiSpeed(data *matrix) *charts.Parallel {
-	parallel := charts.NewParallel()
-	parallel.SetGlobalOptions(
+// handshakeBreakdown stacks, per node, the mean BLE to Wifi time and the
+// mean Wifi to IPFS time that follows, so the whole handshake cost and where
+// it goes are visible in one bar. Nodes without a connection carrying all
+// three timestamps are left out.
+func handshakeBreakdown(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
-			Title: "RSSI Speed",
+			Title: "Handshake time per node",
 		}),
-		charts.WithParallelAxisList(parallelAxisList),
-		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Seconds",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
 		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
 	)
-	items := make([]opts.ParallelData, 0)
-	for _, v := range data.NodeMatrix {
+	type phases struct{ wifi, ipfs float64 }
+	means := map[string]phases{}
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		var wifiSum, ipfsSum float64
+		n := 0
 		for _, k := range v.ConnectionHistory {
-			items = append(items, opts.ParallelData{Value: []interface{}{k.RSSI, k.Speed}})
+			if wifi, ipfs, ok := handshakePhases(k); ok {
+				wifiSum += wifi
+				ipfsSum += ipfs
+				n++
+			}
+		}
+		if n > 0 {
+			means[id] = phases{wifiSum / float64(n), ipfsSum / float64(n)}
 		}
 	}
-	parallel.AddSeries("RSSI Speed", items)
-	return parallel
+	nodes := make([]string, 0, len(means))
+	for id := range means {
+		nodes = append(nodes, id)
+	}
+	sort.Strings(nodes)
+	wifiItems := make([]opts.BarData, 0, len(nodes))
+	ipfsItems := make([]opts.BarData, 0, len(nodes))
+	for _, id := range nodes {
+		wifiItems = append(wifiItems, opts.BarData{Value: math.Round(means[id].wifi*10) / 10})
+		ipfsItems = append(ipfsItems, opts.BarData{Value: math.Round(means[id].ipfs*10) / 10})
+	}
+	addCaption(&bar.Title, completeness(len(nodes), len(nodeIDs(data)), "nodes with complete handshake timestamps"))
+	bar.SetXAxis(nodes).
+		AddSeries("BLE to Wifi", wifiItems, withStack("handshake")).
+		AddSeries("Wifi to IPFS", ipfsItems, withStack("handshake"))
+	return bar
 }
 
-func downloadSpeed(data *matrix) *charts.Line {
+// discoveryDelayTrend plots each node's BLE to IPFS delays in the order the
+// sessions happened, one line per node, so repeated connections getting
+// faster or slower stand out. Nodes without delays are left out.
+func discoveryDelayTrend(data *matrix) *charts.Line {
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithTitleOpts(
 			opts.Title{
-				Title: "Download Speed",
+				Title: "BLE to IPFS delay trend per node",
 			},
 		),
 		charts.WithXAxisOpts(
 			opts.XAxis{
-				Name: "Count",
+				Name: "Session",
 			},
 		),
 		charts.WithYAxisOpts(
 			opts.YAxis{
-				Name: "MBps",
+				Name: "Seconds",
 			},
 		),
-		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
 		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
 	)
-	xAxis := []int{}
-	yAxis := make([]opts.LineData, 0)
-	for _, v := range data.ContentMatrix {
-		xAxis = append(xAxis, len(xAxis))
-		s := fmt.Sprintf("%.1f", v.AvgSpeed)
-		f, _ := strconv.ParseFloat(s, 64)
-		yAxis = append(yAxis, opts.LineData{Value: f})
+	nodes := []string{}
+	sessions := 0
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		if len(v.DiscoveryDelays) > 0 {
+			nodes = append(nodes, id)
+		}
+		if len(v.DiscoveryDelays) > sessions {
+			sessions = len(v.DiscoveryDelays)
+		}
 	}
-
-	line.SetXAxis(xAxis).AddSeries("Download Speed", yAxis).
-		SetSeriesOptions(
-			charts.WithAreaStyleOpts(opts.AreaStyle{
-				Opacity: 0.2,
-			}),
-		)
+	addCaption(&line.Title, completeness(len(nodes), len(nodeIDs(data)), "nodes with discovery delays"))
+	line.SetXAxis(countIndices(sessions))
+	for _, id := range nodes {
+		yAxis := make([]opts.LineData, 0)
+		for _, k := range data.NodeMatrix[id].DiscoveryDelays {
+			yAxis = append(yAxis, opts.LineData{Value: k})
+		}
+		line.AddSeries(id, yAxis)
+	}
+	line.SetSeriesOptions(
+		charts.WithLineChartOpts(opts.LineChart{
+			Smooth: true,
+		}),
+	)
+	return line
+}
+
+// fewDelays is how many discovery delays a node needs before its box in
+// discoveryDelayBoxplot is more than a rough guide.
+const fewDelays = 5
+
+// discoveryDelayBoxplot shows the spread of each node's discovery delays as
+// min, Q1, median, Q3 and max. Nodes with fewer than fewDelays delays are
+// labelled with their count, and left out if -min-samples sets a boxplot
+// minimum they don't meet; the chart is a placeholder when no node meets it.
+func discoveryDelayBoxplot(data *matrix) *charts.BoxPlot {
+	box := charts.NewBoxPlot()
+	box.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Discovery delay spread per node",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Seconds",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBoxPlot)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	min, most := cfg.MinSamples["boxplot"], 0
+	for _, id := range nodeIDs(data) {
+		if n := len(data.NodeMatrix[id].DiscoveryDelays); n > most {
+			most = n
+		}
+	}
+	if !enoughSamples(&box.Title, "boxplot", most) {
+		return box
+	}
+	ids := []string{}
+	items := []opts.BoxPlotData{}
+	marked := false
+	for _, id := range nodeIDs(data) {
+		delays := data.NodeMatrix[id].DiscoveryDelays
+		if len(delays) == 0 || len(delays) < min {
+			continue
+		}
+		label := id
+		if len(delays) < fewDelays {
+			label = fmt.Sprintf("%s (n=%d)", id, len(delays))
+			marked = true
+		}
+		values := make([]float64, 0, len(delays))
+		for _, d := range delays {
+			values = append(values, float64(d))
+		}
+		box5 := make([]float64, 0, 5)
+		for _, p := range []float64{0, 25, 50, 75, 100} {
+			box5 = append(box5, math.Round(percentile(values, p)*10)/10)
+		}
+		ids = append(ids, label)
+		items = append(items, opts.BoxPlotData{Name: label, Value: box5})
+	}
+	kept := "nodes with delays"
+	if min > 0 {
+		kept = fmt.Sprintf("nodes with %d+ delays", min)
+	}
+	addCaption(&box.Title, completeness(len(ids), len(nodeIDs(data)), kept))
+	if marked {
+		addCaption(&box.Title, fmt.Sprintf("(n=…) marks nodes with fewer than %d delays", fewDelays))
+	}
+	box.SetXAxis(ids).AddSeries("Discovery delay", items)
+	return box
+}
+
+// connectionTimeline breaks each of a node's connections into its BLE to
+// Wifi, Wifi to IPFS and IPFS to disconnect phases, stacked. A phase whose
+// timestamps weren't both recorded has no width.
+func connectionTimeline(nodeID string, data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Connection timeline of " + nodeID,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Seconds",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	history := data.NodeMatrix[nodeID].ConnectionHistory
+	connections := make([]string, 0, len(history))
+	wifi := make([]opts.BarData, 0, len(history))
+	ipfs := make([]opts.BarData, 0, len(history))
+	connected := make([]opts.BarData, 0, len(history))
+	phase := func(start, end int64) opts.BarData {
+		return opts.BarData{Value: math.Round(math.Max(durationSeconds(start, end), 0)*10) / 10}
+	}
+	// Category axes grow upwards, so add the latest connection first to show
+	// the first one on top.
+	for i := len(history) - 1; i >= 0; i-- {
+		k := history[i]
+		connections = append(connections, strconv.Itoa(i))
+		wifi = append(wifi, phase(k.BLEDiscoveredAt, k.WifiConnectedAt))
+		ipfs = append(ipfs, phase(k.WifiConnectedAt, k.IPFSConnectedAt))
+		connected = append(connected, phase(k.IPFSConnectedAt, k.DisconnectedAt))
+	}
+	addCaption(&bar.Title, fmt.Sprintf("%d connections", len(history)))
+	bar.SetXAxis(connections).
+		AddSeries("BLE to Wifi", wifi, withStack("timeline")).
+		AddSeries("Wifi to IPFS", ipfs, withStack("timeline")).
+		AddSeries("IPFS to disconnect", connected, withStack("timeline"))
+	bar.XYReversal()
+	return bar
+}
+
+// nodeRSSI plots the RSSI of each of a node's connections in turn.
+// Connections without a recorded or plausible RSSI leave a gap.
+func nodeRSSI(nodeID string, data *matrix) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "RSSI of " + nodeID,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Connection",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "dBm",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	history := data.NodeMatrix[nodeID].ConnectionHistory
+	xAxis := make([]int, 0, len(history))
+	yAxis := make([]opts.LineData, 0, len(history))
+	recorded := 0
+	filter := rssiFilter{chart: "node-rssi"}
+	for i, k := range history {
+		xAxis = append(xAxis, i)
+		if !filter.keep(k.RSSI) {
+			yAxis = append(yAxis, opts.LineData{Value: "-"})
+			continue
+		}
+		yAxis = append(yAxis, opts.LineData{Value: k.RSSI})
+		recorded++
+	}
+	filter.report()
+	addCaption(&line.Title, completeness(recorded, len(history), "connections with RSSI"))
+	line.SetXAxis(xAxis).AddSeries("RSSI", yAxis)
+	return line
+}
+
+// nodeDiscoveryDelays plots a node's BLE discovery to IPFS connection
+// delays in the order they were logged.
+func nodeDiscoveryDelays(nodeID string, data *matrix) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Discovery delays of " + nodeID,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Session",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Seconds",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	delays := data.NodeMatrix[nodeID].DiscoveryDelays
+	xAxis := make([]int, 0, len(delays))
+	yAxis := make([]opts.LineData, 0, len(delays))
+	for i, d := range delays {
+		xAxis = append(xAxis, i)
+		yAxis = append(yAxis, opts.LineData{Value: d})
+	}
+	addCaption(&line.Title, fmt.Sprintf("%d sessions", len(delays)))
+	line.SetXAxis(xAxis).AddSeries("BLE to IPFS", yAxis)
+	return line
+}
+
+// nodeConnectionOutcomes splits a node's connection attempts into
+// successes and failures.
+func nodeConnectionOutcomes(nodeID string, data *matrix) *charts.Pie {
+	pie := charts.NewPie()
+	pie.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Connection attempts of " + nodeID,
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartPie)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	v := data.NodeMatrix[nodeID]
+	addCaption(&pie.Title, fmt.Sprintf("%d attempts", v.ConnectionSuccessCount+v.ConnectionFailureCount))
+	pie.AddSeries("Attempts", []opts.PieData{
+		{Name: "Succeeded", Value: v.ConnectionSuccessCount},
+		{Name: "Failed", Value: v.ConnectionFailureCount},
+	}).SetSeriesOptions(
+		charts.WithLabelOpts(opts.Label{
+			Show:      true,
+			Formatter: "{b}: {c} ({d}%)",
+		}),
+	)
+	return pie
+}
+
+// healthScore combines a node's success rate with one session's signal and
+// link speed into a 0-100 score. Each part is normalised to 0-1 and the parts
+// are averaged; RSSI and speed are skipped when they weren't recorded.
+func healthScore(successRate float64, k ConnectionInfo, maxSpeed int) float64 {
+	parts := []float64{successRate}
+	if k.RSSI != 0 {
+		parts = append(parts, math.Max(0, math.Min(1, float64(k.RSSI+100)/80)))
+	}
+	if k.Speed != 0 && maxSpeed > 0 {
+		parts = append(parts, float64(k.Speed)/float64(maxSpeed))
+	}
+	var sum float64
+	for _, p := range parts {
+		sum += p
+	}
+	return sum / float64(len(parts)) * 100
+}
+
+// networkHealth plots each node's composite health score across its
+// sessions, one line per node, to show whether it is improving or degrading.
+func networkHealth(data *matrix) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(
+			opts.Title{
+				Title:    "Network health per node",
+				Subtitle: "score = 100 × mean(success rate, (RSSI + 100) / 80, speed / max speed)",
+			},
+		),
+		charts.WithXAxisOpts(
+			opts.XAxis{
+				Name: "Session",
+			},
+		),
+		charts.WithYAxisOpts(
+			opts.YAxis{
+				Name: "Score",
+				Min:  0,
+				Max:  100,
+			},
+		),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	nodes := nodeIDs(data)
+	sessions, maxSpeed := 0, 0
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		if len(v.ConnectionHistory) > sessions {
+			sessions = len(v.ConnectionHistory)
+		}
+		for _, k := range v.ConnectionHistory {
+			if k.Speed > maxSpeed {
+				maxSpeed = k.Speed
+			}
+		}
+	}
+	line.SetXAxis(countIndices(sessions))
+	scored := 0
+	for _, id := range nodes {
+		v := data.NodeMatrix[id]
+		attempts := v.ConnectionSuccessCount + v.ConnectionFailureCount
+		if attempts == 0 {
+			continue
+		}
+		successRate := float64(v.ConnectionSuccessCount) / float64(attempts)
+		history := append([]ConnectionInfo(nil), v.ConnectionHistory...)
+		sort.SliceStable(history, func(i, j int) bool { return history[i].BLEDiscoveredAt < history[j].BLEDiscoveredAt })
+		yAxis := make([]opts.LineData, 0, len(history))
+		for _, k := range history {
+			yAxis = append(yAxis, opts.LineData{Value: math.Round(healthScore(successRate, k, maxSpeed)*10) / 10})
+		}
+		line.AddSeries(id, yAxis)
+		scored++
+	}
+	addCaption(&line.Title, completeness(scored, len(nodes), "nodes with connection attempts"))
+	return line
+}
+
+// rssiOverTime plots each node's RSSI over its successive connections,
+// oldest first. Connections without a recorded or plausible RSSI leave a
+// gap.
+func rssiOverTime(data *matrix) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(
+			opts.Title{
+				Title: "RSSI over successive connections",
+			},
+		),
+		charts.WithXAxisOpts(
+			opts.XAxis{
+				Name: "Connection",
+			},
+		),
+		charts.WithYAxisOpts(
+			opts.YAxis{
+				Name: "dBm",
+			},
+		),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	connections := 0
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		if len(v.ConnectionHistory) > connections {
+			connections = len(v.ConnectionHistory)
+		}
+	}
+	line.SetXAxis(countIndices(connections))
+	recorded, total := 0, 0
+	filter := rssiFilter{chart: "rssi-over-time"}
+	for _, id := range nodeIDs(data) {
+		history := append([]ConnectionInfo(nil), data.NodeMatrix[id].ConnectionHistory...)
+		sort.SliceStable(history, func(i, j int) bool { return history[i].BLEDiscoveredAt < history[j].BLEDiscoveredAt })
+		yAxis := make([]opts.LineData, 0, len(history))
+		for _, k := range history {
+			total++
+			if !filter.keep(k.RSSI) {
+				yAxis = append(yAxis, opts.LineData{Value: "-"})
+				continue
+			}
+			yAxis = append(yAxis, opts.LineData{Value: k.RSSI})
+			recorded++
+		}
+		line.AddSeries(id, yAxis)
+	}
+	filter.report()
+	addCaption(&line.Title, completeness(recorded, total, "connections with RSSI"))
+	return line
+}
+
+var (
+	parallelAxisList = []opts.ParallelAxis{
+		{Dim: 0, Name: "RSSI"},
+		{Dim: 1, Name: "Speed"},
+		{Dim: 2, Name: "MHz"},
+	}
+)
+
+// rssiSpeed plots each connection's RSSI, link speed and frequency on
+// parallel axes, split by band. Connections without a recorded or plausible
+// RSSI are left out.
+func rssiSpeed(data *matrix) *charts.Parallel {
+	parallel := charts.NewParallel()
+	parallel.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "RSSI Speed",
+		}),
+		charts.WithParallelAxisList(parallelAxisList),
+		charts.WithTooltipOpts(tooltip(types.ChartParallel)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	points, frequencies := [][2]float64{}, []int{}
+	total := 0
+	filter := rssiFilter{chart: "rssi-speed"}
+	for _, id := range nodeIDs(data) {
+		for _, k := range data.NodeMatrix[id].ConnectionHistory {
+			total++
+			if !filter.keep(k.RSSI) {
+				continue
+			}
+			points = append(points, [2]float64{float64(k.RSSI), float64(k.Speed)})
+			frequencies = append(frequencies, k.Frequency)
+		}
+	}
+	// Thinned across bands together so each keeps its share of the points.
+	shown := subsampleGridIndices(points, cfg.ScatterMaxPoints)
+	bands := map[string][]opts.ParallelData{}
+	for _, i := range shown {
+		band := frequencyBand(frequencies[i])
+		bands[band] = append(bands[band], opts.ParallelData{Value: []interface{}{points[i][0], points[i][1], frequencies[i]}})
+	}
+	filter.report()
+	addCaption(&parallel.Title, completeness(len(shown), total, "connections"))
+	for _, band := range frequencyBands {
+		if items, ok := bands[band]; ok {
+			parallel.AddSeries(band, items)
+		}
+	}
+	return parallel
+}
+
+// frequencyUsage counts connection samples per exact Wifi frequency so
+// channel usage is visible. Samples without a frequency are counted as
+// "unknown".
+func frequencyUsage(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Connections per Wifi frequency",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "MHz",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Count",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	counts := map[int]int{}
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		for _, k := range v.ConnectionHistory {
+			counts[k.Frequency]++
+		}
+	}
+	frequencies := make([]int, 0, len(counts))
+	for f := range counts {
+		if f != 0 {
+			frequencies = append(frequencies, f)
+		}
+	}
+	sort.Ints(frequencies)
+	xAxis := make([]string, 0, len(counts))
+	items := make([]opts.BarData, 0, len(counts))
+	for _, f := range frequencies {
+		xAxis = append(xAxis, strconv.Itoa(f))
+		items = append(items, opts.BarData{Value: counts[f]})
+	}
+	if n, ok := counts[0]; ok {
+		xAxis = append(xAxis, "unknown")
+		items = append(items, opts.BarData{Value: n})
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	addCaption(&bar.Title, completeness(total-counts[0], total, "connections with a frequency"))
+	bar.SetXAxis(xAxis).AddSeries("Connections", items)
+	return bar
+}
+
+// connectionHeatmap counts connection attempts by the hour of the day, in
+// UTC, that the node was discovered. When the log spans more than one day
+// the attempts are split by day of the week too. Connections without a
+// discovery time are left out.
+func connectionHeatmap(data *matrix) *charts.HeatMap {
+	var times []time.Time
+	total := 0
+	days := map[string]bool{}
+	for _, id := range nodeIDs(data) {
+		for _, k := range data.NodeMatrix[id].ConnectionHistory {
+			total++
+			if k.BLEDiscoveredAt == 0 {
+				continue
+			}
+			t := timestampTime(k.BLEDiscoveredAt)
+			times = append(times, t)
+			days[t.Format("2006-01-02")] = true
+		}
+	}
+	rows := []string{"All days"}
+	row := func(time.Time) int { return 0 }
+	if len(days) > 1 {
+		rows = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+		row = func(t time.Time) int { return int(t.Weekday()) }
+	}
+	counts := map[[2]int]int{}
+	most := 0
+	for _, t := range times {
+		cell := [2]int{t.Hour(), row(t)}
+		counts[cell]++
+		if counts[cell] > most {
+			most = counts[cell]
+		}
+	}
+	hours := make([]string, 0, 24)
+	for h := 0; h < 24; h++ {
+		hours = append(hours, strconv.Itoa(h))
+	}
+	items := make([]opts.HeatMapData, 0, len(counts))
+	for y := range rows {
+		for x := range hours {
+			if n := counts[[2]int{x, y}]; n > 0 {
+				items = append(items, opts.HeatMapData{Value: [3]int{x, y, n}})
+			}
+		}
+	}
+
+	heatmap := charts.NewHeatMap()
+	heatmap.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Connection attempts by hour of day",
+		}),
+		// HeatMap drops the data given to SetXAxis, so set it here.
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Hour (UTC)",
+			Type: "category",
+			Data: hours,
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Type: "category",
+			Data: rows,
+		}),
+		charts.WithVisualMapOpts(opts.VisualMap{
+			Calculable: true,
+			Max:        float32(most),
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartHeatMap)),
+	)
+	addCaption(&heatmap.Title, completeness(len(times), total, "connections with a discovery time"))
+	heatmap.AddSeries("Connection attempts", items)
+	return heatmap
+}
+
+// speedByBand compares the mean link speed of connections on each Wifi band,
+// with a line from the slowest to the fastest connection on the band.
+// Connections without a recorded speed or frequency are left out.
+func speedByBand(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Link speed per Wifi band",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Mbps",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	bands := []string{"2.4GHz", "5GHz"}
+	speeds := map[string][]float64{}
+	total := 0
+	for _, id := range nodeIDs(data) {
+		for _, k := range data.NodeMatrix[id].ConnectionHistory {
+			total++
+			if k.Speed > 0 && k.Frequency != 0 {
+				band := frequencyBand(k.Frequency)
+				speeds[band] = append(speeds[band], float64(k.Speed))
+			}
+		}
+	}
+	items := make([]opts.BarData, 0, len(bands))
+	ranges := &opts.MarkLines{MarkLineStyle: opts.MarkLineStyle{
+		Symbol: []string{"rect", "rect"},
+		Label:  &opts.Label{Show: true, Formatter: "{c}"},
+	}}
+	used := 0
+	for _, band := range bands {
+		values := speeds[band]
+		used += len(values)
+		if len(values) == 0 {
+			items = append(items, opts.BarData{Value: "-"})
+			continue
+		}
+		sum, lo, hi := 0.0, values[0], values[0]
+		for _, v := range values {
+			sum += v
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		items = append(items, opts.BarData{Value: math.Round(sum/float64(len(values))*10) / 10})
+		// echarts draws a mark line between two coordinates given as a pair.
+		ranges.Data = append(ranges.Data, []map[string]interface{}{
+			{"name": band + " range", "coord": []interface{}{band, lo}},
+			{"coord": []interface{}{band, hi}},
+		})
+	}
+	addCaption(&bar.Title, completeness(used, total, "connections with speed and frequency"))
+	bar.SetXAxis(bands).AddSeries("Mean speed", items, func(s *charts.SingleSeries) {
+		if len(ranges.Data) > 0 {
+			s.MarkLines = ranges
+		}
+	})
+	return bar
+}
+
+// rssiSpeedCorrelation correlates, per node, the RSSI of the connection a
+// download went over with the speed of the content the node provided. Nodes
+// with fewer paired samples than the correlation minimum are left out, and
+// the chart is a placeholder when no node has enough.
+func rssiSpeedCorrelation(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "RSSI vs download speed correlation per node",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Pearson r",
+			Min:  -1,
+			Max:  1,
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	rssi, speed := map[string][]float64{}, map[string][]float64{}
+	filter := rssiFilter{chart: "rssi-speed-correlation"}
+	for _, cid := range contentIDs(data) {
+		c := data.ContentMatrix[cid]
+		if c.DownloadStartedAt == 0 {
+			continue
+		}
+		for _, id := range providersOf(c) {
+			// The unknown provider has no node entry and so no connection.
+			k, ok := connectionAt(data.NodeMatrix[id], c.DownloadStartedAt)
+			if !ok || !filter.keep(k.RSSI) {
+				continue
+			}
+			rssi[id] = append(rssi[id], float64(k.RSSI))
+			speed[id] = append(speed[id], float64(c.AvgSpeed))
+		}
+	}
+	filter.report()
+	most := 0
+	for _, xs := range rssi {
+		if len(xs) > most {
+			most = len(xs)
+		}
+	}
+	if !enoughSamples(&bar.Title, "correlation", most) {
+		return bar
+	}
+	nodes := make([]string, 0, len(rssi))
+	for id, xs := range rssi {
+		if len(xs) >= cfg.MinSamples["correlation"] {
+			nodes = append(nodes, id)
+		}
+	}
+	sort.Strings(nodes)
+	items := make([]opts.BarData, 0, len(nodes))
+	for _, id := range nodes {
+		items = append(items, opts.BarData{Value: math.Round(pearson(rssi[id], speed[id])*100) / 100})
+	}
+	addCaption(&bar.Title, completeness(len(nodes), len(nodeIDs(data)),
+		fmt.Sprintf("nodes with at least %d paired samples", cfg.MinSamples["correlation"])))
+	bar.SetXAxis(nodes).AddSeries("Correlation", items)
+	return bar
+}
+
+// rssiVsDiscoveryDelay plots each node's median RSSI against its median
+// discovery delay, one point per node named after it, to show whether weak
+// signal slows discovery. Implausible RSSI readings are ignored, and nodes
+// without both an RSSI and a delay recorded are left out.
+func rssiVsDiscoveryDelay(data *matrix) *charts.Scatter {
+	scatter := charts.NewScatter()
+	scatter.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Discovery delay by RSSI",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Median dBm",
+			Type: "value",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Median seconds",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartScatter)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	nodes := nodeIDs(data)
+	items := make([]opts.ScatterData, 0, len(nodes))
+	filter := rssiFilter{chart: "rssi-vs-discovery-delay"}
+	for _, id := range nodes {
+		v := data.NodeMatrix[id]
+		rssi := make([]float64, 0, len(v.ConnectionHistory))
+		for _, k := range v.ConnectionHistory {
+			if filter.keep(k.RSSI) {
+				rssi = append(rssi, float64(k.RSSI))
+			}
+		}
+		delays := make([]float64, 0, len(v.DiscoveryDelays))
+		for _, d := range v.DiscoveryDelays {
+			delays = append(delays, float64(d))
+		}
+		if len(rssi) == 0 || len(delays) == 0 {
+			continue
+		}
+		items = append(items, opts.ScatterData{Name: id, Value: []float64{
+			math.Round(percentile(rssi, 50)*10) / 10,
+			math.Round(percentile(delays, 50)*10) / 10,
+		}})
+	}
+	filter.report()
+	addCaption(&scatter.Title, completeness(len(items), len(nodes), "nodes with RSSI and discovery delays"))
+	scatter.AddSeries("Nodes", items)
+	return scatter
+}
+
+func downloadSpeed(sets []dataset) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(
+			opts.Title{
+				Title: "Download Speed",
+			},
+		),
+		charts.WithXAxisOpts(
+			opts.XAxis{
+				Name: "Count",
+			},
+		),
+		charts.WithYAxisOpts(
+			opts.YAxis{
+				Name: "MBps",
+			},
+		),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	longest := 0
+	for _, set := range sets {
+		values := downloadSpeeds(set.Data)
+		yAxis := make([]opts.LineData, 0, len(values))
+		for _, v := range values {
+			yAxis = append(yAxis, opts.LineData{Value: math.Round(v*10) / 10})
+		}
+		addCaption(&line.Title, set.caption(completeness(len(yAxis), len(set.Data.ContentMatrix), "content items")))
+
+		line.AddSeries(set.series("Download Speed"), thinLine(yAxis, values),
+			withAreaFill(),
+			withPercentileLines(values),
+			withAnnotations(),
+		)
+		if len(yAxis) > longest {
+			longest = len(yAxis)
+		}
+	}
+	line.SetXAxis(countIndices(longest))
+	return line
+}
+
+// downloadThroughput plots the throughput worked out from each content
+// item's Size and download timestamps next to the AvgSpeed it reported, in
+// the order the downloads started, so the two can be checked against each
+// other. Items without a positive download duration are left out.
+func downloadThroughput(data *matrix) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Download throughput",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Minutes",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "MBps",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	items := make([]ContentMatrix, 0, len(data.ContentMatrix))
+	for _, cid := range contentIDs(data) {
+		if _, ok := throughput(data.ContentMatrix[cid]); ok {
+			items = append(items, data.ContentMatrix[cid])
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DownloadStartedAt < items[j].DownloadStartedAt })
+	xAxis := make([]float64, 0, len(items))
+	derived := make([]opts.LineData, 0, len(items))
+	reported := make([]opts.LineData, 0, len(items))
+	for _, v := range items {
+		mbps, _ := throughput(v)
+		xAxis = append(xAxis, math.Round(durationSeconds(items[0].DownloadStartedAt, v.DownloadStartedAt)/60*10)/10)
+		derived = append(derived, opts.LineData{Value: math.Round(mbps*10) / 10})
+		reported = append(reported, opts.LineData{Value: math.Round(float64(v.AvgSpeed)*10) / 10})
+	}
+	addCaption(&line.Title, completeness(len(items), len(data.ContentMatrix), "content items with a download duration"))
+	line.SetXAxis(xAxis).
+		AddSeries("Size / duration", derived).
+		AddSeries("Reported AvgSpeed", reported)
+	return line
+}
+
+// downloadSpeedHistogram counts content items by AvgSpeed in cfg.HistogramBins
+// equal-width bins. Failed downloads, with no speed, are left out.
+func downloadSpeedHistogram(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Download speed distribution",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "MBps",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Content items",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	values := downloadSpeeds(data)
+	edges, width, counts := histogram(values, cfg.HistogramBins)
+	xAxis := make([]string, 0, len(edges))
+	items := make([]opts.BarData, 0, len(counts))
+	for i, lo := range edges {
+		label := fmt.Sprintf("%.1f", lo)
+		if width > 0 {
+			label = fmt.Sprintf("%.1f-%.1f", lo, lo+width)
+		}
+		xAxis = append(xAxis, label)
+		items = append(items, opts.BarData{Value: counts[i]})
+	}
+	addCaption(&bar.Title, completeness(len(values), len(data.ContentMatrix), "content items with a speed"))
+	bar.SetXAxis(xAxis).AddSeries("Content items", items)
+	return bar
+}
+
+// contentSize plots the size of each content item in download order. With
+// -log-axis content-size the y axis is logarithmic, so small items stay
+// visible next to large ones, and items without a size are left out.
+func contentSize(data *matrix) *charts.Bar {
+	logScale := cfg.LogAxis["content-size"]
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Content size",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Count",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "MB",
+			Type: valueAxisType(logScale),
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	items := make([]ContentMatrix, 0, len(data.ContentMatrix))
+	for _, v := range data.ContentMatrix {
+		if logScale && v.Size <= 0 {
+			continue
+		}
+		items = append(items, v)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].DownloadStartedAt != items[j].DownloadStartedAt {
+			return items[i].DownloadStartedAt < items[j].DownloadStartedAt
+		}
+		return items[i].Tag < items[j].Tag
+	})
+	xAxis := make([]int, 0, len(items))
+	sizes := make([]opts.BarData, 0, len(items))
+	for i, v := range items {
+		xAxis = append(xAxis, i)
+		sizes = append(sizes, opts.BarData{Name: v.Tag, Value: math.Round(float64(v.Size)/1e6*10) / 10})
+	}
+	addCaption(&bar.Title, completeness(len(items), len(data.ContentMatrix), "content items"))
+	bar.SetXAxis(xAxis).AddSeries("Size", sizes)
+	return bar
+}
+
+// sizeVsSpeed plots each content item's download speed against its size, to
+// show whether bigger downloads sustain higher speeds.
+func sizeVsSpeed(data *matrix) *charts.Scatter {
+	logScale := cfg.LogAxis["size-vs-speed"]
+	xType := valueAxisType(logScale)
+	if xType == "" {
+		xType = "value"
+	}
+	scatter := charts.NewScatter()
+	scatter.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Download speed by content size",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "MB",
+			Type: xType,
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "MBps",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartScatter)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	points, tags := [][2]float64{}, []string{}
+	for _, cid := range contentIDs(data) {
+		v := data.ContentMatrix[cid]
+		if logScale && v.Size <= 0 {
+			continue
+		}
+		points = append(points, [2]float64{math.Round(float64(v.Size)/1e6*10) / 10, math.Round(float64(v.AvgSpeed)*10) / 10})
+		tags = append(tags, v.Tag)
+	}
+	shown := subsampleGridIndices(points, cfg.ScatterMaxPoints)
+	items := make([]opts.ScatterData, 0, len(shown))
+	for _, i := range shown {
+		items = append(items, opts.ScatterData{Name: tags[i], Value: []float64{points[i][0], points[i][1]}})
+	}
+	addCaption(&scatter.Title, completeness(len(items), len(data.ContentMatrix), "content items"))
+	scatter.AddSeries("Content", items)
+	return scatter
+}
+
+// providerCounts shows how many distinct peers provided each content item,
+// best replicated at the top. Content without a known provider counts zero.
+func providerCounts(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Distinct providers per content",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Providers",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	type replication struct {
+		tag       string
+		providers int
+	}
+	items := make([]replication, 0, len(data.ContentMatrix))
+	for _, v := range data.ContentMatrix {
+		providers := providersOf(v)
+		n := len(providers)
+		if providers[0] == unknownProvider {
+			n = 0
+		}
+		items = append(items, replication{tag: v.Tag, providers: n})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].providers != items[j].providers {
+			return items[i].providers > items[j].providers
+		}
+		return items[i].tag < items[j].tag
+	})
+	// Category axes grow upwards, so add the best replicated item last to show it on top.
+	tags := make([]string, 0, len(items))
+	counts := make([]opts.BarData, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		tags = append(tags, items[i].tag)
+		counts = append(counts, opts.BarData{Value: items[i].providers})
+	}
+	addCaption(&bar.Title, completeness(len(items), len(data.ContentMatrix), "content items"))
+	bar.SetXAxis(tags).AddSeries("Providers", counts)
+	bar.XYReversal()
+	return bar
+}
+
+// providerContribution shows how many content items each peer provided,
+// largest share first. An item provided by several peers counts for each,
+// and items whose provider wasn't logged are left out. Labels show the last
+// 8 characters of the peer ID; the full ID is in the tooltip.
+func providerContribution(data *matrix) *charts.Pie {
+	pie := charts.NewPie()
+	pie.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Content provided per peer",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartPie)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	counts := map[string]int{}
+	known := 0
+	for _, cid := range contentIDs(data) {
+		providers := providersOf(data.ContentMatrix[cid])
+		if providers[0] == unknownProvider {
+			continue
+		}
+		known++
+		for _, id := range providers {
+			counts[id]++
+		}
+	}
+	peers := make([]string, 0, len(counts))
+	for id := range counts {
+		peers = append(peers, id)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if counts[peers[i]] != counts[peers[j]] {
+			return counts[peers[i]] > counts[peers[j]]
+		}
+		return peers[i] < peers[j]
+	})
+	items := make([]opts.PieData, 0, len(peers))
+	for _, id := range peers {
+		label := id
+		if len(label) > 8 {
+			label = label[len(label)-8:]
+		}
+		items = append(items, opts.PieData{Name: label, Value: counts[id], Tooltip: &opts.Tooltip{Show: true, Formatter: id + "<br/>{c} content items ({d}%)"}})
+	}
+	addCaption(&pie.Title, completeness(known, len(data.ContentMatrix), "content items with a known provider"))
+	pie.AddSeries("Providers", items).SetSeriesOptions(
+		charts.WithLabelOpts(opts.Label{
+			Show:      true,
+			Formatter: "{b}: {c} ({d}%)",
+		}),
+	)
+	return pie
+}
+
+// slowestDownloads lists the content items with the lowest AvgSpeed, worst
+// at the top. Ties are broken by Size, larger first.
+func slowestDownloads(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: fmt.Sprintf("Top %d slowest downloads", cfg.SlowestN),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "MBps",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	items := make([]ContentMatrix, 0, len(data.ContentMatrix))
+	for _, v := range data.ContentMatrix {
+		items = append(items, v)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].AvgSpeed != items[j].AvgSpeed {
+			return items[i].AvgSpeed < items[j].AvgSpeed
+		}
+		if items[i].Size != items[j].Size {
+			return items[i].Size > items[j].Size
+		}
+		return items[i].Tag < items[j].Tag
+	})
+	if len(items) > cfg.SlowestN {
+		items = items[:cfg.SlowestN]
+	}
+	// Category axes grow upwards, so add the slowest item last to show it on top.
+	tags := make([]string, 0, len(items))
+	speeds := make([]opts.BarData, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		tags = append(tags, items[i].Tag)
+		speeds = append(speeds, opts.BarData{Value: math.Round(float64(items[i].AvgSpeed)*10) / 10})
+	}
+	addCaption(&bar.Title, completeness(len(items), len(data.ContentMatrix), "content items"))
+	bar.SetXAxis(tags).AddSeries("Download Speed", speeds)
+	bar.XYReversal()
+	return bar
+}
+
+// connectionSuccessRateByNode plots the percentage of each node's
+// connection attempts that succeeded. Nodes that never attempted a
+// connection are left out.
+func connectionSuccessRateByNode(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Connection success rate per node",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "%",
+			Max:  100,
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	ids := make([]string, 0, len(data.NodeMatrix))
+	rates := make([]opts.BarData, 0, len(data.NodeMatrix))
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		attempts := v.ConnectionSuccessCount + v.ConnectionFailureCount
+		if attempts == 0 {
+			continue
+		}
+		ids = append(ids, id)
+		rates = append(rates, opts.BarData{Value: math.Round(float64(v.ConnectionSuccessCount)/float64(attempts)*1000) / 10})
+	}
+	addCaption(&bar.Title, completeness(len(ids), len(nodeIDs(data)), "nodes with connection attempts"))
+	bar.SetXAxis(ids).AddSeries("Success rate", rates)
+	return bar
+}
+
+// flakiestNodes lists the nodes with the highest connection failure ratio,
+// worst at the top. Nodes with fewer than -flaky-min-attempts attempts are
+// left out so a single failed attempt doesn't dominate.
+func flakiestNodes(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: fmt.Sprintf("Top %d flakiest nodes", cfg.FlakiestN),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Failure %",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	type flaky struct {
+		id    string
+		ratio float64
+	}
+	nodes := make([]flaky, 0, len(data.NodeMatrix))
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		attempts := v.ConnectionSuccessCount + v.ConnectionFailureCount
+		if attempts == 0 || attempts < cfg.FlakyMinAttempts {
+			continue
+		}
+		nodes = append(nodes, flaky{id: id, ratio: float64(v.ConnectionFailureCount) / float64(attempts) * 100})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].ratio != nodes[j].ratio {
+			return nodes[i].ratio > nodes[j].ratio
+		}
+		return nodes[i].id < nodes[j].id
+	})
+	addCaption(&bar.Title, completeness(len(nodes), len(nodeIDs(data)),
+		fmt.Sprintf("nodes with at least %d attempts", cfg.FlakyMinAttempts)))
+	if len(nodes) > cfg.FlakiestN {
+		nodes = nodes[:cfg.FlakiestN]
+	}
+	// Category axes grow upwards, so add the flakiest node last to show it on top.
+	ids := make([]string, 0, len(nodes))
+	ratios := make([]opts.BarData, 0, len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		ids = append(ids, nodes[i].id)
+		ratios = append(ratios, opts.BarData{Value: math.Round(nodes[i].ratio*10) / 10})
+	}
+	bar.SetXAxis(ids).AddSeries("Failure ratio", ratios)
+	bar.XYReversal()
+	return bar
+}
+
+// lastSessionDuration ranks nodes by how long their last successful
+// connection lasted, longest first. The duration is logged in the unit of
+// the node's timestamps. Nodes without one show as zero unless
+// -skip-zero-sessions leaves them out.
+func lastSessionDuration(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Last successful connection duration per node",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Seconds",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	type session struct {
+		id       string
+		duration float64
+	}
+	nodes := nodeIDs(data)
+	sessions := make([]session, 0, len(nodes))
+	for _, id := range nodes {
+		v := data.NodeMatrix[id]
+		d := float64(v.LastSuccessfulConnectionDuration)
+		if v.IPFSConnectedAt >= msTimestamp || v.BLEDiscoveredAt >= msTimestamp {
+			d /= 1000
+		}
+		if d <= 0 && cfg.SkipZeroSessions {
+			continue
+		}
+		sessions = append(sessions, session{id, d})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].duration != sessions[j].duration {
+			return sessions[i].duration > sessions[j].duration
+		}
+		return sessions[i].id < sessions[j].id
+	})
+	addCaption(&bar.Title, completeness(len(sessions), len(nodes), "nodes"))
+	// Category axes grow upwards, so add the longest session last to show it on top.
+	ids := make([]string, 0, len(sessions))
+	durations := make([]opts.BarData, 0, len(sessions))
+	for i := len(sessions) - 1; i >= 0; i-- {
+		ids = append(ids, sessions[i].id)
+		durations = append(durations, opts.BarData{Value: math.Round(sessions[i].duration*10) / 10})
+	}
+	bar.SetXAxis(ids).AddSeries("Duration", durations)
+	bar.XYReversal()
+	return bar
+}
+
+// connectionAge shows how long each currently connected node has held its
+// IPFS connection, oldest at the top. The age runs up to the time of the
+// render, given in the caption, so every render of the page, including
+// -watch re-renders, brings the ages up to date.
+func connectionAge(data *matrix) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Connected nodes by session age",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Hours",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	type session struct {
+		id  string
+		age time.Duration
+	}
+	at := now()
+	sessions := make([]session, 0, len(data.NodeMatrix))
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		if !v.ConnectionAlive || v.IPFSConnectedAt == 0 {
+			continue
+		}
+		sessions = append(sessions, session{id: id, age: at.Sub(timestampTime(v.IPFSConnectedAt))})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].age != sessions[j].age {
+			return sessions[i].age < sessions[j].age
+		}
+		return sessions[i].id > sessions[j].id
+	})
+	addCaption(&bar.Title, completeness(len(sessions), len(nodeIDs(data)), "nodes currently connected"))
+	addCaption(&bar.Title, "ages as of "+at.UTC().Format("2006-01-02 15:04 UTC"))
+	// Sorted youngest first, since category axes grow upwards.
+	ids := make([]string, 0, len(sessions))
+	ages := make([]opts.BarData, 0, len(sessions))
+	for _, v := range sessions {
+		ids = append(ids, v.id)
+		ages = append(ages, opts.BarData{Value: math.Round(v.age.Hours()*10) / 10})
+	}
+	bar.SetXAxis(ids).AddSeries("Session age", ages)
+	bar.XYReversal()
+	return bar
+}
+
+// connectionDuration plots how long each finished connection stayed up,
+// from IPFS connection to disconnect, in the order the connections started.
+// Connections still up have no DisconnectedAt and are left out.
+func connectionDuration(data *matrix) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(
+			opts.Title{
+				Title: "Connection duration",
+			},
+		),
+		charts.WithXAxisOpts(
+			opts.XAxis{
+				Name: "Connection",
+			},
+		),
+		charts.WithYAxisOpts(
+			opts.YAxis{
+				Name: "Seconds",
+			},
+		),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	type connection struct {
+		id       string
+		start    int64
+		duration float64
+	}
+	connections, total := []connection{}, 0
+	for _, id := range nodeIDs(data) {
+		for _, k := range data.NodeMatrix[id].ConnectionHistory {
+			total++
+			if k.IPFSConnectedAt == 0 || k.DisconnectedAt == 0 {
+				continue
+			}
+			connections = append(connections, connection{id, k.IPFSConnectedAt, durationSeconds(k.IPFSConnectedAt, k.DisconnectedAt)})
+		}
+	}
+	sort.SliceStable(connections, func(i, j int) bool { return connections[i].start < connections[j].start })
+	xAxis := make([]int, 0, len(connections))
+	yAxis := make([]opts.LineData, 0, len(connections))
+	for i, c := range connections {
+		xAxis = append(xAxis, i)
+		yAxis = append(yAxis, opts.LineData{Name: c.id, Value: c.duration})
+	}
+	addCaption(&line.Title, completeness(len(connections), total, "connections ended"))
+	line.SetXAxis(xAxis).AddSeries("Duration", yAxis).
+		SetSeriesOptions(withAreaFill())
+	return line
+}
+
+// downloadCompletion splits content items into those with both download
+// timestamps set and those that never finished, which the speed charts
+// otherwise hide.
+func downloadCompletion(data *matrix) *charts.Pie {
+	pie := charts.NewPie()
+	pie.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Completed vs incomplete downloads",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartPie)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	completed, incomplete := 0, 0
+	for _, v := range data.ContentMatrix {
+		if v.DownloadStartedAt != 0 && v.DownloadFinishedAt != 0 {
+			completed++
+		} else {
+			incomplete++
+		}
+	}
+	addCaption(&pie.Title, completeness(completed, len(data.ContentMatrix), "downloads completed"))
+	pie.AddSeries("Downloads", []opts.PieData{
+		{Name: "Completed", Value: completed},
+		{Name: "Incomplete", Value: incomplete},
+	}).SetSeriesOptions(
+		charts.WithLabelOpts(opts.Label{
+			Show:      true,
+			Formatter: "{b}: {c} ({d}%)",
+		}),
+	)
+	return pie
+}
+
+// aliveStatusSummary counts the nodes whose connection was alive when the
+// log was written against those whose connection wasn't.
+func aliveStatusSummary(data *matrix) *charts.Pie {
+	pie := charts.NewPie()
+	pie.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Nodes alive at the end of the log",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartPie)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	nodes := nodeIDs(data)
+	alive := 0
+	for _, id := range nodes {
+		if data.NodeMatrix[id].ConnectionAlive {
+			alive++
+		}
+	}
+	addCaption(&pie.Title, completeness(alive, len(nodes), "nodes alive"))
+	pie.AddSeries("Nodes", []opts.PieData{
+		{Name: "Alive", Value: alive},
+		{Name: "Dead", Value: len(nodes) - alive},
+	}).SetSeriesOptions(
+		charts.WithLabelOpts(opts.Label{
+			Show:      true,
+			Formatter: "{b}: {c} ({d}%)",
+		}),
+	)
+	return pie
+}
+
+// speedByRSSI bins connections by RSSI and plots the mean link speed per
+// bin, smoothing the noisy RSSI/speed relationship into a trend. Empty bins
+// leave a gap rather than dragging the line to zero.
+func speedByRSSI(data *matrix) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(
+			opts.Title{
+				Title: "Mean link speed by RSSI",
+			},
+		),
+		charts.WithXAxisOpts(
+			opts.XAxis{
+				Name: "dBm",
+			},
+		),
+		charts.WithYAxisOpts(
+			opts.YAxis{
+				Name: "Mbps",
+			},
+		),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	width := cfg.RSSIBinWidth
+	sums, counts := map[int]int{}, map[int]int{}
+	lo, hi := 0, 0
+	total, sampled := 0, 0
+	filter := rssiFilter{chart: "speed-by-rssi"}
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		for _, k := range v.ConnectionHistory {
+			total++
+			// A zero speed means it was never recorded.
+			if !filter.keep(k.RSSI) || k.Speed == 0 {
+				continue
+			}
+			bin := int(math.Floor(float64(k.RSSI)/float64(width))) * width
+			if sampled == 0 || bin < lo {
+				lo = bin
+			}
+			if sampled == 0 || bin > hi {
+				hi = bin
+			}
+			sums[bin] += k.Speed
+			counts[bin]++
+			sampled++
+		}
+	}
+	filter.report()
+	if !enoughSamples(&line.Title, "binned", sampled) {
+		return line
+	}
+	xAxis := []string{}
+	yAxis := make([]opts.LineData, 0)
+	for bin := lo; bin <= hi; bin += width {
+		n := counts[bin]
+		xAxis = append(xAxis, fmt.Sprintf("%d to %d (n=%d)", bin, bin+width-1, n))
+		if n == 0 {
+			yAxis = append(yAxis, opts.LineData{Value: "-"})
+			continue
+		}
+		yAxis = append(yAxis, opts.LineData{Value: math.Round(float64(sums[bin])/float64(n)*10) / 10})
+	}
+	addCaption(&line.Title, completeness(sampled, total, "connections with RSSI and speed"))
+	line.SetXAxis(xAxis).AddSeries("Mean speed", yAxis)
 	return line
 }
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// testNow is the time tests render at.
+var testNow = time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+
+// useTestdata points cfg at the fixtures in testdata and a fresh output
+// directory, with the fixtures as the pages to render, and stops the clock
+// at testNow, restoring it all when the test ends.
+func useTestdata(t *testing.T) {
+	t.Helper()
+	saved, savedMatrix, savedBattery, savedNow := cfg, matrixFiles, batteryMeasurementFiles, now
+	t.Cleanup(func() {
+		cfg, matrixFiles, batteryMeasurementFiles, now = saved, savedMatrix, savedBattery, savedNow
+	})
+	now = func() time.Time { return testNow }
+	cfg.LogsDir = "testdata"
+	cfg.OutDir = t.TempDir()
+	matrixFiles, batteryMeasurementFiles = []string{"fixture_matrix"}, []string{"fixture_battery"}
+}
+
+// readOutput returns the content of the named output file.
+func readOutput(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(cfg.OutDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) == 0 {
+		t.Fatalf("%s is empty", name)
+	}
+	return string(b)
+}
+
+func TestRenderMatrixPage(t *testing.T) {
+	useTestdata(t)
+	if _, err := renderMatrixPage("fixture_matrix"); err != nil {
+		t.Fatal(err)
+	}
+	html := readOutput(t, "fixture_matrix.html")
+	for _, title := range []string{"BLE to Wifi", "Download Speed", "RSSI Speed"} {
+		if !strings.Contains(html, title) {
+			t.Errorf("fixture_matrix.html has no %q chart", title)
+		}
+	}
+}
+
+func TestRenderBatteryMeasurementPage(t *testing.T) {
+	useTestdata(t)
+	if err := renderBatteryMeasurementPage("fixture_battery"); err != nil {
+		t.Fatal(err)
+	}
+	html := readOutput(t, "fixture_battery.html")
+	for _, title := range []string{"Battery consumption per MB by device", "Projected hours of transfer until empty"} {
+		if !strings.Contains(html, title) {
+			t.Errorf("fixture_battery.html has no %q chart", title)
+		}
+	}
+}
+
+// TestRenderAllConcurrently renders several pages of the same logs at once,
+// so that under -race they share the parsed log cache between workers.
+func TestRenderAllConcurrently(t *testing.T) {
+	useTestdata(t)
+	cfg.Jobs = 4
+	cfg.LogSources = map[string]string{}
+	for i := 0; i < 4; i++ {
+		matrix, battery := fmt.Sprintf("matrix_%d", i), fmt.Sprintf("battery_%d", i)
+		cfg.LogSources[matrix] = filepath.Join("testdata", "fixture_matrix.log")
+		cfg.LogSources[battery] = filepath.Join("testdata", "fixture_battery.log")
+		matrixFiles = append(matrixFiles, matrix)
+		batteryMeasurementFiles = append(batteryMeasurementFiles, battery)
+	}
+	if err := renderAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range append(append([]string{}, matrixFiles...), batteryMeasurementFiles...) {
+		readOutput(t, page+".html")
+	}
+}
+
+// TestRenderMissingPage renders pages with no log, which must fail with an
+// error naming the log while the other pages still render.
+func TestRenderMissingPage(t *testing.T) {
+	useTestdata(t)
+	if _, err := renderMatrixPage("missing"); err == nil || !strings.Contains(err.Error(), "missing.log") {
+		t.Errorf("rendering a missing matrix page: got error %v", err)
+	}
+	if err := renderBatteryMeasurementPage("missing"); err == nil || !strings.Contains(err.Error(), "missing.log") {
+		t.Errorf("rendering a missing battery page: got error %v", err)
+	}
+	matrixFiles = append(matrixFiles, "missing")
+	if err := renderAll(); err == nil {
+		t.Error("renderAll with a missing page returned no error")
+	}
+	readOutput(t, "fixture_matrix.html")
+	readOutput(t, "fixture_battery.html")
+}
+
+// TestChartsDeterministic builds every chart from the fixtures repeatedly,
+// expecting the same series, points and axes in the same order each time
+// however the log's maps happen to iterate.
+func TestChartsDeterministic(t *testing.T) {
+	useTestdata(t)
+	data, err := loadMatrix(filepath.Join("testdata", "fixture_matrix.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	battery, err := loadBatteryMeasurements(filepath.Join("testdata", "fixture_battery.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := func() map[string]string {
+		options := map[string]string{}
+		add := func(name string, chart components.Charter) {
+			option, err := liveOption(chart)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			options[name] = string(option)
+		}
+		for _, c := range matrixCharts {
+			add(c.name, c.build(data))
+		}
+		for _, c := range batteryCharts {
+			add(c.name, c.build(battery))
+		}
+		return options
+	}
+	first := build()
+	for i := 0; i < 20; i++ {
+		for name, option := range build() {
+			if option != first[name] {
+				t.Fatalf("%s differs between builds:\n%s\n%s", name, first[name], option)
+			}
+		}
+	}
+
+	if _, err := renderMatrixPage("fixture_matrix"); err != nil {
+		t.Fatal(err)
+	}
+	html := readOutput(t, "fixture_matrix.html")
+	cfg.OutDir = t.TempDir()
+	if _, err := renderMatrixPage("fixture_matrix"); err != nil {
+		t.Fatal(err)
+	}
+	if readOutput(t, "fixture_matrix.html") != html {
+		t.Error("fixture_matrix.html differs between renders")
+	}
+}
+
+// TestMalformedMeasurementsSkipped loads a battery log with a consumption
+// and an interval that aren't numbers, which must be dropped while the rest
+// chart as numbers.
+func TestMalformedMeasurementsSkipped(t *testing.T) {
+	useTestdata(t)
+	path := filepath.Join(t.TempDir(), "battery.log")
+	log := `{"BatteryMeasurement": [
+		{"DataTransfer": "10", "TransferInterval": "40", "Battery Consumption": "12.5"},
+		{"DataTransfer": "10", "TransferInterval": "120", "Battery Consumption": "n/a"},
+		{"DataTransfer": "10", "TransferInterval": "fast", "Battery Consumption": 3},
+		{"DataTransfer": "10", "TransferInterval": "120", "Battery Consumption": 5}
+	]}`
+	if err := os.WriteFile(path, []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := loadBatteryMeasurements(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.BatteryMeasurement) != 2 {
+		t.Fatalf("kept %d measurements, want the 2 numeric ones: %+v", len(data.BatteryMeasurement), data.BatteryMeasurement)
+	}
+	bar := transferIntervalToBatteryPercentage(data)
+	bar.Validate() // fills in the x axis
+	if got, want := bar.XAxisList[0].Data, []string{"40s", "120s"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("x axis %v, want %v", got, want)
+	}
+	var values []interface{}
+	for _, item := range bar.MultiSeries[0].Data.([]opts.BarData) {
+		values = append(values, item.Value)
+	}
+	if want := []interface{}{12.5, 5.0}; !reflect.DeepEqual(values, want) {
+		t.Errorf("consumption %v, want %v", values, want)
+	}
+}
+
+// TestEmptyProvidedBy charts content without a logged provider, which must
+// count as no provider rather than panic or skew the per-peer counts.
+func TestEmptyProvidedBy(t *testing.T) {
+	useTestdata(t)
+	data := &matrix{
+		ContentMatrix: map[string]ContentMatrix{
+			"QmFailed":  {Tag: "failed", ProvidedBy: nil},
+			"QmEmpty":   {Tag: "empty", ProvidedBy: []string{}, DownloadStartedAt: 1700000000},
+			"QmBlank":   {Tag: "blank", ProvidedBy: []string{""}},
+			"QmTwice":   {Tag: "twice", ProvidedBy: []string{"QmNodeA", "QmNodeA"}},
+			"QmTwoPeer": {Tag: "two peers", ProvidedBy: []string{"QmNodeA", "QmNodeB"}},
+		},
+		NodeMatrix: map[string]DiscoveredNodeMatrix{},
+	}
+	rssiSpeedCorrelation(data)
+
+	pie := providerContribution(data)
+	counts := map[string]interface{}{}
+	for _, item := range pie.MultiSeries[0].Data.([]opts.PieData) {
+		counts[item.Name] = item.Value
+	}
+	if want := map[string]interface{}{"QmNodeA": 2, "QmNodeB": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("content per peer %v, want %v", counts, want)
+	}
+	if !strings.Contains(pie.Title.Subtitle, "2/5 content items") {
+		t.Errorf("caption %q doesn't count 2/5 items with a known provider", pie.Title.Subtitle)
+	}
+
+	bar := providerCounts(data)
+	bar.Validate() // fills in the axis
+	providers := map[string]interface{}{}
+	for i, item := range bar.MultiSeries[0].Data.([]opts.BarData) {
+		providers[bar.YAxisList[0].Data.([]string)[i]] = item.Value
+	}
+	want := map[string]interface{}{"failed": 0, "empty": 0, "blank": 0, "twice": 1, "two peers": 2}
+	if !reflect.DeepEqual(providers, want) {
+		t.Errorf("providers per content %v, want %v", providers, want)
+	}
+}
+
+// TestIdleConsumption charts measurements twice as long as the idle one,
+// which must subtract twice the idle consumption while still reporting the
+// idle figure as measured.
+func TestIdleConsumption(t *testing.T) {
+	useTestdata(t)
+	cfg.BatteryHours = 2 * idleHours
+	data := &BatteryMeasurements{BatteryMeasurement: []Measurement{
+		{DataTransfer: "10", TransferInterval: "40", BatteryConsumption: 9},
+	}}
+	bar := transferIntervalToBatteryPercentageOnlyDatahop(data)
+	if want := fmt.Sprintf("Idle Device consumed %d%% after %d hours", idleConsumption, idleHours); !strings.Contains(bar.Title.Subtitle, want) {
+		t.Errorf("subtitle %q, want %q", bar.Title.Subtitle, want)
+	}
+	if got := bar.MultiSeries[0].Data.([]opts.BarData)[0].Value; got != 9.0-2*idleConsumption {
+		t.Errorf("consumption less idle %v, want %v", got, 9.0-2*idleConsumption)
+	}
+}
+
+// TestEmptyChartsRender builds every chart from logs with nothing in them,
+// which must caption the chart rather than panic.
+func TestEmptyChartsRender(t *testing.T) {
+	empty := &matrix{ContentMatrix: map[string]ContentMatrix{}, NodeMatrix: map[string]DiscoveredNodeMatrix{}}
+	var built []components.Charter
+	for _, c := range matrixCharts {
+		built = append(built, c.build(empty))
+	}
+	for _, c := range batteryCharts {
+		built = append(built, c.build(&BatteryMeasurements{}))
+	}
+	for _, c := range compareCharts {
+		built = append(built, c.build([]dataset{{Data: empty}}))
+	}
+	page := components.NewPage()
+	for _, chart := range built {
+		markEmpty(chart)
+		page.AddCharts(chart)
+	}
+	var buf bytes.Buffer
+	if err := page.Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), noDataCaption) {
+		t.Errorf("no chart of the empty logs is captioned %q", noDataCaption)
+	}
+}
+
+func TestDiscoveryDelayBoxplotFewDelays(t *testing.T) {
+	useTestdata(t)
+	data := &matrix{NodeMatrix: map[string]DiscoveredNodeMatrix{
+		"few":  {DiscoveryDelays: []int64{1, 2, 3}},
+		"many": {DiscoveryDelays: []int64{1, 2, 3, 4, 5, 6}},
+	}}
+	boxes := func() []string {
+		var names []string
+		for _, item := range discoveryDelayBoxplot(data).MultiSeries[0].Data.([]opts.BoxPlotData) {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+	if got := boxes(); !reflect.DeepEqual(got, []string{"few (n=3)", "many"}) {
+		t.Errorf("boxes %v, want the node with few delays marked", got)
+	}
+	cfg.MinSamples = map[string]int{"boxplot": fewDelays}
+	if got := boxes(); !reflect.DeepEqual(got, []string{"many"}) {
+		t.Errorf("boxes %v with -min-samples boxplot=%d, want only the node with enough delays", got, fewDelays)
+	}
+}
+
+func TestNotEnoughSamples(t *testing.T) {
+	useTestdata(t)
+	cfg.MinSamples = map[string]int{"boxplot": 10, "correlation": 10}
+	data := &matrix{
+		NodeMatrix: map[string]DiscoveredNodeMatrix{
+			"node": {
+				DiscoveryDelays:   []int64{1, 2, 3},
+				ConnectionHistory: []ConnectionInfo{{BLEDiscoveredAt: 100, IPFSConnectedAt: 110, DisconnectedAt: 200, RSSI: -60}},
+			},
+		},
+		ContentMatrix: map[string]ContentMatrix{
+			"content": {AvgSpeed: 5, DownloadStartedAt: 150, DownloadFinishedAt: 160, ProvidedBy: []string{"node"}},
+		},
+	}
+	for name, title := range map[string]string{
+		"boxplot":     discoveryDelayBoxplot(data).Title.Subtitle,
+		"correlation": rssiSpeedCorrelation(data).Title.Subtitle,
+	} {
+		if !strings.HasPrefix(title, "not enough data") {
+			t.Errorf("%s subtitle is %q, want the not enough data placeholder", name, title)
+		}
+	}
+}
+
+func TestDownloadSpeedHistogramSkipsFailed(t *testing.T) {
+	useTestdata(t)
+	data := &matrix{ContentMatrix: map[string]ContentMatrix{
+		"a": {AvgSpeed: 2}, "b": {AvgSpeed: 4}, "failed": {AvgSpeed: 0},
+	}}
+	total := 0
+	for _, item := range downloadSpeedHistogram(data).MultiSeries[0].Data.([]opts.BarData) {
+		total += item.Value.(int)
+	}
+	if total != 2 {
+		t.Errorf("histogram counts %d content items, want the 2 that downloaded", total)
+	}
+}
+
+func TestConnectionAge(t *testing.T) {
+	useTestdata(t)
+	const hour = 3600
+	for _, scale := range []int64{1, 1000} {
+		start := testNow.Add(-2*time.Hour).Unix() * scale
+		data := &matrix{NodeMatrix: map[string]DiscoveredNodeMatrix{
+			"up":   {ConnectionAlive: true, IPFSConnectedAt: start},
+			"down": {BLEDiscoveredAt: start - hour*scale},
+		}}
+		bar := connectionAge(data)
+		ages := bar.MultiSeries[0].Data.([]opts.BarData)
+		if len(ages) != 1 || ages[0].Value != 2.0 {
+			t.Errorf("timestamps scaled by %d: ages %v, want the 2 hours up to now", scale, ages)
+		}
+		if want := "ages as of 2023-11-15 12:00 UTC"; !strings.Contains(bar.Title.Subtitle, want) {
+			t.Errorf("caption %q doesn't give the time the ages run to", bar.Title.Subtitle)
+		}
+	}
+}
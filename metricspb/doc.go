@@ -0,0 +1,4 @@
+// Package metricspb holds the gRPC service exposing the parsed matrix logs.
+package metricspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
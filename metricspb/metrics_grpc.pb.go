@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: metrics.proto
+
+package metricspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// MetricsClient is the client API for Metrics service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MetricsClient interface {
+	// GetSummary returns the headline numbers of a page.
+	GetSummary(ctx context.Context, in *SummaryRequest, opts ...grpc.CallOption) (*Summary, error)
+	// StreamSamples streams every connection of a page, ordered by node.
+	StreamSamples(ctx context.Context, in *SamplesRequest, opts ...grpc.CallOption) (Metrics_StreamSamplesClient, error)
+}
+
+type metricsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMetricsClient(cc grpc.ClientConnInterface) MetricsClient {
+	return &metricsClient{cc}
+}
+
+func (c *metricsClient) GetSummary(ctx context.Context, in *SummaryRequest, opts ...grpc.CallOption) (*Summary, error) {
+	out := new(Summary)
+	err := c.cc.Invoke(ctx, "/matrixcharts.v1.Metrics/GetSummary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricsClient) StreamSamples(ctx context.Context, in *SamplesRequest, opts ...grpc.CallOption) (Metrics_StreamSamplesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Metrics_ServiceDesc.Streams[0], "/matrixcharts.v1.Metrics/StreamSamples", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricsStreamSamplesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Metrics_StreamSamplesClient interface {
+	Recv() (*Sample, error)
+	grpc.ClientStream
+}
+
+type metricsStreamSamplesClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricsStreamSamplesClient) Recv() (*Sample, error) {
+	m := new(Sample)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MetricsServer is the server API for Metrics service.
+// All implementations must embed UnimplementedMetricsServer
+// for forward compatibility
+type MetricsServer interface {
+	// GetSummary returns the headline numbers of a page.
+	GetSummary(context.Context, *SummaryRequest) (*Summary, error)
+	// StreamSamples streams every connection of a page, ordered by node.
+	StreamSamples(*SamplesRequest, Metrics_StreamSamplesServer) error
+	mustEmbedUnimplementedMetricsServer()
+}
+
+// UnimplementedMetricsServer must be embedded to have forward compatible implementations.
+type UnimplementedMetricsServer struct {
+}
+
+func (UnimplementedMetricsServer) GetSummary(context.Context, *SummaryRequest) (*Summary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSummary not implemented")
+}
+func (UnimplementedMetricsServer) StreamSamples(*SamplesRequest, Metrics_StreamSamplesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSamples not implemented")
+}
+func (UnimplementedMetricsServer) mustEmbedUnimplementedMetricsServer() {}
+
+// UnsafeMetricsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MetricsServer will
+// result in compilation errors.
+type UnsafeMetricsServer interface {
+	mustEmbedUnimplementedMetricsServer()
+}
+
+func RegisterMetricsServer(s grpc.ServiceRegistrar, srv MetricsServer) {
+	s.RegisterService(&Metrics_ServiceDesc, srv)
+}
+
+func _Metrics_GetSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsServer).GetSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/matrixcharts.v1.Metrics/GetSummary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsServer).GetSummary(ctx, req.(*SummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Metrics_StreamSamples_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SamplesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricsServer).StreamSamples(m, &metricsStreamSamplesServer{stream})
+}
+
+type Metrics_StreamSamplesServer interface {
+	Send(*Sample) error
+	grpc.ServerStream
+}
+
+type metricsStreamSamplesServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricsStreamSamplesServer) Send(m *Sample) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Metrics_ServiceDesc is the grpc.ServiceDesc for Metrics service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Metrics_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "matrixcharts.v1.Metrics",
+	HandlerType: (*MetricsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSummary",
+			Handler:    _Metrics_GetSummary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSamples",
+			Handler:       _Metrics_StreamSamples_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "metrics.proto",
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+// renderPage renders page to w, inserting header HTML just after the
+// opening body tag and footer HTML just before the closing one.
+func renderPage(page *components.Page, w io.Writer, header, footer string) error {
+	var buf bytes.Buffer
+	if err := page.Render(&buf); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	if footer != "" {
+		if i := bytes.LastIndex(out, []byte("</body>")); i >= 0 {
+			out = append(out[:i:i], append([]byte(footer), out[i:]...)...)
+		}
+	}
+	if header != "" {
+		if i := bytes.Index(out, []byte("<body>")); i >= 0 {
+			i += len("<body>")
+			out = append(out[:i:i], append([]byte(header), out[i:]...)...)
+		}
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// summaryCard returns a one-row table of a matrix page's download totals,
+// shown above its charts.
+func summaryCard(data *matrix) string {
+	return fmt.Sprintf(`<table class="summary" style="margin:20px auto;border-collapse:collapse;text-align:center">
+    <tr><th style="padding:0 20px">Content items</th><th style="padding:0 20px">Total downloaded</th><th style="padding:0 20px">Mean speed</th></tr>
+    <tr><td>%d</td><td>%s</td><td>%.1f MBps</td></tr>
+</table>`, len(data.ContentMatrix), humanBytes(totalDownloadSize(data)), meanDownloadSpeed(data))
+}
+
+// humanBytes formats n bytes in decimal units, e.g. 50.0 MB.
+func humanBytes(n int64) string {
+	const unit, prefixes = 1000, "kMGTPE"
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < len(prefixes)-1 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %cB", value, prefixes[prefix])
+}
+
+// fileSafe replaces the characters of s that don't belong in a file name,
+// such as the slash in the ID of a merged node, with underscores.
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// indexEntry is a rendered page as listed on the index. Nodes are the node
+// pages of a matrix page, listed under it.
+type indexEntry struct {
+	File  string
+	Name  string
+	Title string
+	CSV   string
+	Nodes []indexEntry
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`
+<h1 style="text-align:center">Datahop Charts</h1>
+<ul style="width:900px;margin:0 auto">
+{{- range .}}
+    <li><a href="{{.File}}">{{.Name}}</a> &ndash; {{.Title}}{{if .CSV}} (<a href="{{.CSV}}">CSV</a>){{end}}
+    {{- if .Nodes}}
+        <ul>
+        {{- range .Nodes}}
+            <li><a href="{{.File}}">{{.Name}}</a></li>
+        {{- end}}
+        </ul>
+    {{- end}}
+    </li>
+{{- end}}
+</ul>
+`))
+
+// renderIndex writes index.html to the output directory, linking to each
+// of the rendered pages, with the uptime of each matrix page charted below.
+func renderIndex(pages []indexEntry, summaries []pageSummary) error {
+	var links bytes.Buffer
+	if err := indexTemplate.Execute(&links, pages); err != nil {
+		return err
+	}
+	page := components.NewPage()
+	page.PageTitle = indexPageTitle
+	if len(summaries) > 0 {
+		chart := uptimeByPage(summaries)
+		setChartID(chart, newRand())
+		setTheme(chart)
+		setSize(chart)
+		colorSeries(chart)
+		page.AddCharts(chart)
+	}
+	f, err := os.Create(outPath("index.html"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return renderPage(page, f, links.String(), "")
+}
+
+// uptimeByPage compares the TotalUptime of the matrix pages, in hours.
+func uptimeByPage(summaries []pageSummary) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Total uptime per log",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Hours",
+		}),
+		charts.WithTooltipOpts(tooltip(types.ChartBar)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	names := make([]string, 0, len(summaries))
+	items := make([]opts.BarData, 0, len(summaries))
+	for _, s := range summaries {
+		names = append(names, s.Page)
+		items = append(items, opts.BarData{Value: math.Round(float64(s.TotalUptime)/3600*100) / 100})
+	}
+	bar.SetXAxis(names).AddSeries("Uptime", items)
+	return bar
+}
+
+// rawDataFooter returns a collapsed block holding v as pretty-printed JSON
+// when -embed-raw is set, so the source data can be inspected from the page.
+func rawDataFooter(v interface{}) (string, error) {
+	if !cfg.EmbedRaw {
+		return "", nil
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`<details style="margin:30px auto;width:900px"><summary>Raw log data</summary><pre>%s</pre></details>`,
+		html.EscapeString(string(b))), nil
+}
+
+// gridStyle lays a page's charts out in -columns columns, collapsing to a
+// single column on screens too narrow to fit them. One column keeps the
+// default stacked layout.
+func gridStyle() string {
+	if cfg.Columns <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(`<style>
+    body { display: grid; grid-template-columns: repeat(%d, minmax(0, 1fr)); }
+    body > .container .item { max-width: 100%%; }
+    body > details, body > .summary { grid-column: 1 / -1; }
+    @media (max-width: %dpx) { body { grid-template-columns: minmax(0, 1fr); } }
+</style>`, cfg.Columns, cfg.Columns*600)
+}
+
+// tabsScript puts a page's charts behind a tab bar when -layout is tabs,
+// showing one chart at a time. Tabs are labelled with the chart titles. A
+// chart is resized as its tab is shown since it can't be sized while
+// hidden.
+func tabsScript() string {
+	if cfg.Layout != "tabs" {
+		return ""
+	}
+	return `<style>
+    .tabs { display: flex; flex-wrap: wrap; justify-content: center; gap: 4px; margin: 20px auto; max-width: 1200px; }
+    .tabs button { padding: 6px 12px; border: 1px solid #ccc; border-radius: 4px; background: #f7f7f7; cursor: pointer; }
+    .tabs button.active { background: #5470c6; border-color: #5470c6; color: #fff; }
+</style>
+<script type="text/javascript">
+(function () {
+    var containers = document.querySelectorAll("body > .container");
+    if (containers.length === 0) { return; }
+    var tabs = document.createElement("div"), buttons = [];
+    tabs.className = "tabs";
+    function show(n) {
+        containers.forEach(function (c, i) {
+            c.style.display = i === n ? "" : "none";
+            buttons[i].classList.toggle("active", i === n);
+        });
+        var item = containers[n].querySelector(".item");
+        var chart = item && echarts.getInstanceByDom(item);
+        if (chart) { chart.resize(); }
+    }
+    containers.forEach(function (c, i) {
+        var item = c.querySelector(".item"), chart = item && echarts.getInstanceByDom(item);
+        var title = chart && chart.getOption().title;
+        var button = document.createElement("button");
+        button.type = "button";
+        button.textContent = (title && title[0] && title[0].text) || "Chart " + (i + 1);
+        button.onclick = function () { show(i); };
+        buttons.push(button);
+        tabs.appendChild(button);
+    });
+    containers[0].parentNode.insertBefore(tabs, containers[0]);
+    show(0);
+})();
+</script>`
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-echarts/go-echarts/v2/components"
+)
+
+// browsers are the headless-capable browsers looked for on PATH to take PNG
+// snapshots, in order of preference.
+var browsers = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "headless-shell"}
+
+var findBrowser = sync.OnceValues(func() (string, error) {
+	if path := os.Getenv("CHROME_PATH"); path != "" {
+		return path, nil
+	}
+	for _, name := range browsers {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("-format png needs Chrome or Chromium to take snapshots: install one of " +
+		strings.Join(browsers, ", ") + " or point CHROME_PATH at it")
+})
+
+// snapshotChart writes chart as <page>/<chart>.png in the output directory
+// when -format is png, by rendering it alone and screenshotting it in a
+// headless browser. The chart must be -chart-width by -chart-height pixels,
+// which is what the screenshot is cropped to.
+func snapshotChart(pageName, chartName string, chart components.Charter) error {
+	if cfg.Format != "png" {
+		return nil
+	}
+	browser, err := findBrowser()
+	if err != nil {
+		return err
+	}
+	r, ok := chart.(interface{ Render(io.Writer) error })
+	if !ok {
+		return fmt.Errorf("chart type %s can't be rendered on its own", chart.Type())
+	}
+	tmp, err := ioutil.TempFile("", "matrix-charts-*.html")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// Without the page margin the chart fills the window exactly.
+	if _, err := io.WriteString(tmp, "<style>body { margin: 0; }</style>\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := r.Render(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outPath(pageName), 0755); err != nil {
+		return err
+	}
+	out, err := filepath.Abs(outPath(filepath.Join(pageName, chartName+".png")))
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(browser, "--headless", "--disable-gpu", "--hide-scrollbars",
+		"--virtual-time-budget=5000",
+		"--window-size="+strings.TrimSuffix(cfg.ChartWidth, "px")+","+strings.TrimSuffix(cfg.ChartHeight, "px"),
+		"--screenshot="+out, "file://"+tmp.Name())
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("snapshotting %s with %s: %v: %s", chartName, browser, err, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// qualityIssue is a single suspicious record found in a log. Index is the
+// connection within the node's history, or -1 when the issue concerns the
+// node as a whole.
+type qualityIssue struct {
+	Node    string `json:"node"`
+	Index   int    `json:"index"`
+	Problem string `json:"problem"`
+}
+
+// qualityReport collects the issues found while rendering one page.
+type qualityReport struct {
+	Page   string         `json:"page"`
+	Issues []qualityIssue `json:"issues"`
+}
+
+func (r *qualityReport) add(node string, index int, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, qualityIssue{Node: node, Index: index, Problem: fmt.Sprintf(format, args...)})
+}
+
+// write stores the report as pretty-printed JSON at path.
+func (r *qualityReport) write(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// checkMatrix runs every data-quality check on data.
+func checkMatrix(data *matrix, report *qualityReport) {
+	checkMonotonic(data, report)
+	checkHandshakes(data, report)
+}
+
+// timestampInversion reports the first pair of phase timestamps in k that
+// run backwards. Missing (zero) timestamps are ignored.
+func timestampInversion(k ConnectionInfo) (string, bool) {
+	phases := []struct {
+		name string
+		at   int64
+	}{
+		{"BLEDiscoveredAt", k.BLEDiscoveredAt},
+		{"WifiConnectedAt", k.WifiConnectedAt},
+		{"IPFSConnectedAt", k.IPFSConnectedAt},
+	}
+	prev := -1
+	for i, p := range phases {
+		if p.at == 0 {
+			continue
+		}
+		if prev >= 0 && p.at < phases[prev].at {
+			return fmt.Sprintf("%s %d is before %s %d", p.name, p.at, phases[prev].name, phases[prev].at), true
+		}
+		prev = i
+	}
+	return "", false
+}
+
+// checkMonotonic adds an issue for every connection whose phase timestamps
+// are out of order, which points at clock problems or a buggy exporter.
+func checkMonotonic(data *matrix, report *qualityReport) {
+	nodes := nodeIDs(data)
+	for _, id := range nodes {
+		for i, k := range data.NodeMatrix[id].ConnectionHistory {
+			if problem, ok := timestampInversion(k); ok {
+				report.add(id, i, "%s", problem)
+			}
+		}
+	}
+}
+
+// handshakePhases splits a connection's handshake into BLE to Wifi and
+// Wifi to IPFS seconds. ok is false unless all three timestamps are set.
+func handshakePhases(k ConnectionInfo) (wifi, ipfs float64, ok bool) {
+	if k.BLEDiscoveredAt == 0 || k.WifiConnectedAt == 0 || k.IPFSConnectedAt == 0 {
+		return 0, 0, false
+	}
+	return durationSeconds(k.BLEDiscoveredAt, k.WifiConnectedAt), durationSeconds(k.WifiConnectedAt, k.IPFSConnectedAt), true
+}
+
+// checkHandshakes adds an issue for every node with connections but none
+// carrying all the handshake timestamps, since it can't appear in the
+// handshake breakdown.
+func checkHandshakes(data *matrix, report *qualityReport) {
+	nodes := nodeIDs(data)
+	for _, id := range nodes {
+		history := data.NodeMatrix[id].ConnectionHistory
+		complete := false
+		for _, k := range history {
+			if _, _, ok := handshakePhases(k); ok {
+				complete = true
+				break
+			}
+		}
+		if len(history) > 0 && !complete {
+			report.add(id, -1, "no connection has BLE, Wifi and IPFS timestamps; left out of the handshake breakdown")
+		}
+	}
+}
+
+// dropNonMonotonic returns a copy of data without the connections whose
+// phase timestamps are out of order. data itself is left untouched since it
+// may be shared through the log cache.
+func dropNonMonotonic(data *matrix) *matrix {
+	out := *data
+	out.NodeMatrix = make(map[string]DiscoveredNodeMatrix, len(data.NodeMatrix))
+	for id, v := range data.NodeMatrix {
+		history := make([]ConnectionInfo, 0, len(v.ConnectionHistory))
+		for _, k := range v.ConnectionHistory {
+			if _, bad := timestampInversion(k); !bad {
+				history = append(history, k)
+			}
+		}
+		v.ConnectionHistory = history
+		out.NodeMatrix[id] = v
+	}
+	return &out
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestHandshakePhases(t *testing.T) {
+	for _, scale := range []int64{1, 1000} {
+		start := int64(1700000000) * scale
+		k := ConnectionInfo{BLEDiscoveredAt: start, WifiConnectedAt: start + 3*scale, IPFSConnectedAt: start + 5*scale}
+		wifi, ipfs, ok := handshakePhases(k)
+		if !ok || wifi != 3 || ipfs != 2 {
+			t.Errorf("timestamps scaled by %d: handshakePhases = %g, %g, %v, want 3, 2, true", scale, wifi, ipfs, ok)
+		}
+	}
+	if _, _, ok := handshakePhases(ConnectionInfo{BLEDiscoveredAt: 1, IPFSConnectedAt: 2}); ok {
+		t.Error("handshakePhases is ok without a Wifi timestamp")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+)
+
+// runMetrics are the headline numbers that can be trended across runs.
+var runMetrics = map[string]struct {
+	name  string
+	unit  string
+	value func(*matrix) float64
+}{
+	"speed":   {"Mean download speed", "MBps", meanDownloadSpeed},
+	"success": {"Connection success rate", "%", connectionSuccessRate},
+}
+
+var runDatePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
+type run struct {
+	name  string
+	date  time.Time
+	value float64
+}
+
+// runDate takes the date of a run from a YYYY-MM-DD stamp in its file name,
+// falling back to the file's modification time.
+func runDate(info os.FileInfo) time.Time {
+	if stamp := runDatePattern.FindString(info.Name()); stamp != "" {
+		if t, err := time.Parse("2006-01-02", stamp); err == nil {
+			return t
+		}
+	}
+	return info.ModTime()
+}
+
+func renderRunTrendPage(dir, metric string) error {
+	m, ok := runMetrics[metric]
+	if !ok {
+		return fmt.Errorf("unknown run metric %q", metric)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	runs := make([]run, 0, len(infos))
+	seen := map[string]bool{}
+	for _, info := range infos {
+		page, ok := logFileName(info)
+		if !ok || seen[page] {
+			continue // not a log, or both page.log and page.log.gz
+		}
+		seen[page] = true
+		data, err := loadMatrix(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return fmt.Errorf("%s: %w", info.Name(), err)
+		}
+		runs = append(runs, run{name: info.Name(), date: runDate(info), value: m.value(data)})
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].date.Before(runs[j].date) })
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(
+			opts.Title{
+				Title:    fmt.Sprintf("%s across runs", m.name),
+				Subtitle: fmt.Sprintf("%d runs from %s", len(runs), dir),
+			},
+		),
+		charts.WithXAxisOpts(
+			opts.XAxis{
+				Name: "Run",
+			},
+		),
+		charts.WithYAxisOpts(
+			opts.YAxis{
+				Name: m.unit,
+			},
+		),
+		charts.WithTooltipOpts(tooltip(types.ChartLine)),
+		charts.WithLegendOpts(opts.Legend{Show: true, Left: "80%"}),
+	)
+	xAxis := make([]string, 0, len(runs))
+	yAxis := make([]opts.LineData, 0, len(runs))
+	for _, r := range runs {
+		xAxis = append(xAxis, r.date.Format("2006-01-02"))
+		yAxis = append(yAxis, opts.LineData{Name: r.name, Value: r.value})
+	}
+	line.SetXAxis(xAxis).AddSeries(m.name, yAxis)
+	markEmpty(line)
+	setChartID(line, newRand())
+	setTheme(line)
+	setSize(line)
+	colorSeries(line)
+
+	page := components.NewPage()
+	page.AddCharts(line)
+	page.PageTitle = runsPageTitle
+	f, err := os.Create(outPath("cross_run_trend.html"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return page.Render(io.MultiWriter(f))
+}
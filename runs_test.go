@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunTrendPageReproducible renders the trend page twice with the same
+// seed and expects the same HTML, chart IDs included.
+func TestRunTrendPageReproducible(t *testing.T) {
+	useTestdata(t)
+	dir := t.TempDir()
+	log, err := os.ReadFile(filepath.Join("testdata", "fixture_matrix.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"run-2024-01-01.log", "run-2024-01-08.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), log, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var renders []string
+	for i := 0; i < 2; i++ {
+		if err := renderRunTrendPage(dir, "speed"); err != nil {
+			t.Fatal(err)
+		}
+		renders = append(renders, readOutput(t, "cross_run_trend.html"))
+	}
+	if renders[0] != renders[1] {
+		t.Error("two renders with the same seed differ")
+	}
+}
+
+func TestRunTrendPageEmpty(t *testing.T) {
+	useTestdata(t)
+	if err := renderRunTrendPage(t.TempDir(), "speed"); err != nil {
+		t.Fatal(err)
+	}
+	if html := readOutput(t, "cross_run_trend.html"); !strings.Contains(html, noDataCaption) {
+		t.Errorf("the trend of no runs isn't captioned %q", noDataCaption)
+	}
+}
+
+func TestRunTrendPageGzip(t *testing.T) {
+	useTestdata(t)
+	dir := t.TempDir()
+	log, err := os.ReadFile(filepath.Join("testdata", "fixture_matrix.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run-2024-01-01.log"), log, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(log)
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "run-2024-01-08.log.gz"), gz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := renderRunTrendPage(dir, "speed"); err != nil {
+		t.Fatal(err)
+	}
+	if html := readOutput(t, "cross_run_trend.html"); !strings.Contains(html, "2 runs from") {
+		t.Error("the gzipped run is missing from the trend")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish once the
+// servers are told to stop.
+const shutdownTimeout = 10 * time.Second
+
+// healthzPath answers load balancer health checks.
+const healthzPath = "/healthz"
+
+// serve runs the dashboard server on addr until ctx is cancelled, then shuts
+// it down, giving open requests up to shutdownTimeout, and returns. With
+// tlsCert and tlsKey set it serves HTTPS using that certificate and key.
+// listening, if not nil, is called with the listener's address once it
+// accepts connections.
+func serve(ctx context.Context, addr, tlsCert, tlsKey string, listening func(net.Addr)) error {
+	fs := http.FileServer(http.Dir(cfg.OutDir))
+	srv := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Health checks arrive every few seconds; keep them out of the log.
+			if r.URL.Path == healthzPath {
+				writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+				return
+			}
+			slog.Debug("request", "remote", r.RemoteAddr, "method", r.Method, "url", r.URL.String())
+			if strings.HasPrefix(r.URL.Path, chartAPIPrefix) {
+				serveChartJSON(w, r)
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, liveAPIPrefix) {
+				serveLive(w, r)
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, exportAPIPrefix) {
+				serveExport(w, r)
+				return
+			}
+			fs.ServeHTTP(w, r)
+		}),
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if tlsCert != "" {
+			slog.Info("running server", "url", "https://"+lis.Addr().String())
+			errc <- srv.ServeTLS(lis, tlsCert, tlsKey)
+			return
+		}
+		slog.Info("running server", "url", "http://"+lis.Addr().String())
+		errc <- srv.Serve(lis)
+	}()
+	if listening != nil {
+		listening(lis.Addr())
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		live.close()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestServeShutdown cancels a running server and expects serve to return
+// without leaving any of its goroutines behind.
+func TestServeShutdown(t *testing.T) {
+	useTestdata(t)
+	saved := live
+	live = &liveHub{pages: map[string]*livePage{}}
+	t.Cleanup(func() { live = saved })
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addrc := make(chan net.Addr, 1)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serve(ctx, "127.0.0.1:0", "", "", func(addr net.Addr) { addrc <- addr })
+	}()
+	var addr net.Addr
+	select {
+	case addr = <-addrc:
+	case err := <-errc:
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{}}
+	resp, err := client.Get("http://" + addr.String() + healthzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("%s answered %s", healthzPath, resp.Status)
+	}
+	client.CloseIdleConnections()
+
+	cancel()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatal("serve didn't return after ctx was cancelled")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left running, want %d:\n%s", runtime.NumGoroutine(), baseline, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRunServeFails starts serve on an address already in use, once for
+// the dashboard and once for gRPC, which must make runServe return the
+// error rather than exit cleanly.
+func TestRunServeFails(t *testing.T) {
+	useTestdata(t)
+	saved := live
+	t.Cleanup(func() { live = saved })
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	for _, args := range [][]string{
+		{"-addr", busy.Addr().String()},
+		{"-addr", "127.0.0.1:0", "-grpc-addr", busy.Addr().String()},
+	} {
+		live = &liveHub{pages: map[string]*livePage{}}
+		done := make(chan error, 1)
+		go func() { done <- runServe(args) }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("runServe %q with the address in use returned no error", args)
+			}
+		case <-time.After(shutdownTimeout):
+			t.Fatalf("runServe %q with the address in use didn't return", args)
+		}
+	}
+}
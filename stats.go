@@ -0,0 +1,399 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// nodeIDs returns the IDs of the nodes in data that pass -filter, sorted so
+// charts built from them come out the same on every run. Every chart takes
+// its nodes from here, so -filter applies to all of them alike.
+func nodeIDs(data *matrix) []string {
+	ids := make([]string, 0, len(data.NodeMatrix))
+	for id := range data.NodeMatrix {
+		if cfg.keepNode(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// contentIDs returns the CIDs of the content items in data, sorted.
+func contentIDs(data *matrix) []string {
+	cids := make([]string, 0, len(data.ContentMatrix))
+	for cid := range data.ContentMatrix {
+		cids = append(cids, cid)
+	}
+	sort.Strings(cids)
+	return cids
+}
+
+// frequencyBand names the Wifi band a frequency in MHz is in: "2.4GHz",
+// "5GHz", or "unknown" for a frequency that wasn't recorded.
+func frequencyBand(mhz int) string {
+	switch {
+	case mhz == 0:
+		return "unknown"
+	case mhz < 3000:
+		return "2.4GHz"
+	default:
+		return "5GHz"
+	}
+}
+
+// frequencyBands lists the names frequencyBand returns, in legend order.
+var frequencyBands = []string{"2.4GHz", "5GHz", "unknown"}
+
+// sortNumeric sorts strings holding numbers by value. Strings that aren't
+// numbers sort by text.
+func sortNumeric(values []string) {
+	sort.Slice(values, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(values[i], 64)
+		b, errB := strconv.ParseFloat(values[j], 64)
+		if errA != nil || errB != nil {
+			return values[i] < values[j]
+		}
+		return a < b
+	})
+}
+
+// meanDownloadSpeed returns the average AvgSpeed of the content items that
+// downloaded, leaving out failed ones as the speed charts do, or zero when
+// none did.
+func meanDownloadSpeed(data *matrix) float64 {
+	speeds := downloadSpeeds(data)
+	if len(speeds) == 0 {
+		return 0
+	}
+	// Summed in content order so the result doesn't vary in the last bits.
+	var sum float64
+	for _, v := range speeds {
+		sum += v
+	}
+	return sum / float64(len(speeds))
+}
+
+// totalDownloadSize returns the summed Size of all content items in bytes.
+func totalDownloadSize(data *matrix) int64 {
+	var total int64
+	for _, v := range data.ContentMatrix {
+		total += v.Size
+	}
+	return total
+}
+
+// connectionSuccessRate returns the percentage of successful connection
+// attempts over all nodes, or zero when no attempts were recorded.
+func connectionSuccessRate(data *matrix) float64 {
+	var success, total int
+	for _, id := range nodeIDs(data) {
+		v := data.NodeMatrix[id]
+		success += v.ConnectionSuccessCount
+		total += v.ConnectionSuccessCount + v.ConnectionFailureCount
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(success) / float64(total) * 100
+}
+
+// pearson returns the Pearson correlation coefficient of xs and ys, which
+// must be the same length. It returns zero when either side has no variance.
+func pearson(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	if n == 0 {
+		return 0
+	}
+	var sx, sy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+	}
+	mx, my := sx/n, sy/n
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// connectionAt returns the node's connection that was up at t: the latest
+// one discovered at or before t that hadn't disconnected yet.
+func connectionAt(node DiscoveredNodeMatrix, t int64) (ConnectionInfo, bool) {
+	var found ConnectionInfo
+	ok := false
+	for _, k := range node.ConnectionHistory {
+		if k.BLEDiscoveredAt == 0 || k.BLEDiscoveredAt > t {
+			continue
+		}
+		if k.DisconnectedAt != 0 && k.DisconnectedAt < t {
+			continue
+		}
+		if !ok || k.BLEDiscoveredAt > found.BLEDiscoveredAt {
+			found, ok = k, true
+		}
+	}
+	return found, ok
+}
+
+// unknownProvider stands in for the provider of content whose ProvidedBy is
+// empty, typically because the download failed.
+const unknownProvider = "unknown provider"
+
+// providersOf returns the distinct peers that provided c, in the order they
+// first appear. Content without any provider is attributed to
+// unknownProvider so per-provider counts still add up.
+func providersOf(c ContentMatrix) []string {
+	providers := make([]string, 0, len(c.ProvidedBy))
+	seen := make(map[string]bool, len(c.ProvidedBy))
+	for _, id := range c.ProvidedBy {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		providers = append(providers, id)
+	}
+	if len(providers) == 0 {
+		providers = append(providers, unknownProvider)
+	}
+	return providers
+}
+
+// msTimestamp is the smallest unix timestamp taken to be in milliseconds;
+// in seconds it would be tens of thousands of years away.
+const msTimestamp = 1e12
+
+// durationSeconds returns the seconds from start to end. The logs record
+// unix seconds, but millisecond timestamps are recognised by their size and
+// scaled so sub-second durations survive. A zero timestamp was never
+// recorded and gives zero.
+func durationSeconds(start, end int64) float64 {
+	if start == 0 || end == 0 {
+		return 0
+	}
+	d := float64(end - start)
+	if start >= msTimestamp || end >= msTimestamp {
+		d /= 1000
+	}
+	return d
+}
+
+// throughput returns the speed of c's download worked out from its Size and
+// how long it took, in the MiB per second AvgSpeed is reported in. ok is
+// false when the timestamps don't give a positive duration.
+func throughput(c ContentMatrix) (mbps float64, ok bool) {
+	d := durationSeconds(c.DownloadStartedAt, c.DownloadFinishedAt)
+	if d <= 0 {
+		return 0, false
+	}
+	return float64(c.Size) / d / (1 << 20), true
+}
+
+// timestampTime returns the UTC time of a log timestamp, which is in unix
+// seconds or, when at least msTimestamp, milliseconds.
+func timestampTime(t int64) time.Time {
+	if t >= msTimestamp {
+		return time.UnixMilli(t).UTC()
+	}
+	return time.Unix(t, 0).UTC()
+}
+
+// now returns the current time. Tests replace it so charts measured against
+// the wall clock come out the same every run.
+var now = time.Now
+
+// movingAverage returns the trailing window-point mean at each of values.
+// The first points, with fewer than window values before them, average what
+// there is, so a window longer than values still gives one mean per value.
+func movingAverage(values []float64, window int) []float64 {
+	if window < 1 {
+		window = 1
+	}
+	out := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= window {
+			sum -= values[i-window]
+		}
+		n := i + 1
+		if n > window {
+			n = window
+		}
+		out[i] = sum / float64(n)
+	}
+	return out
+}
+
+// lttb picks n of values to plot with the largest-triangle-three-buckets
+// method, which keeps the points that shape the line, and returns their
+// indices in order. The first and last values are always kept. All indices
+// are returned when there are no more than n values.
+func lttb(values []float64, n int) []int {
+	if n >= len(values) || n < 3 {
+		if n >= len(values) {
+			return countIndices(len(values))
+		}
+		if n < 2 {
+			n = 2
+		}
+		return []int{0, len(values) - 1}[:n]
+	}
+	kept := make([]int, 0, n)
+	kept = append(kept, 0)
+	// Everything between the endpoints is split into n-2 buckets, each
+	// contributing the point making the largest triangle with the point
+	// kept from the bucket before and the average of the bucket after.
+	width := float64(len(values)-2) / float64(n-2)
+	a := 0
+	for b := 0; b < n-2; b++ {
+		start := int(float64(b)*width) + 1
+		end := int(float64(b+1)*width) + 1
+		nextEnd := int(float64(b+2)*width) + 1
+		if nextEnd > len(values) {
+			nextEnd = len(values)
+		}
+		var avgX, avgY float64
+		for i := end; i < nextEnd; i++ {
+			avgX += float64(i)
+			avgY += values[i]
+		}
+		if count := float64(nextEnd - end); count > 0 {
+			avgX, avgY = avgX/count, avgY/count
+		}
+		best, bestArea := start, -1.0
+		for i := start; i < end; i++ {
+			area := math.Abs((float64(a)-avgX)*(values[i]-values[a]) - (float64(a)-float64(i))*(avgY-values[a]))
+			if area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+		kept = append(kept, best)
+		a = best
+	}
+	return append(kept, len(values)-1)
+}
+
+// countIndices returns 0, 1, ... n-1.
+func countIndices(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// percentile returns the p-th percentile (0-100) of values, interpolating
+// linearly between the closest ranks. It returns zero for no values.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// histogram counts values into bins equal-width bins spanning their range
+// and returns the bins' lower edges, their width and the counts. Values all
+// equal fall into a single bin of zero width.
+func histogram(values []float64, bins int) (edges []float64, width float64, counts []int) {
+	if len(values) == 0 {
+		return nil, 0, nil
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if hi == lo {
+		return []float64{lo}, 0, []int{len(values)}
+	}
+	width = (hi - lo) / float64(bins)
+	edges, counts = make([]float64, bins), make([]int, bins)
+	for i := range edges {
+		edges[i] = lo + float64(i)*width
+	}
+	for _, v := range values {
+		i := int((v - lo) / width)
+		if i >= bins {
+			i = bins - 1 // hi itself closes the last bin
+		}
+		counts[i]++
+	}
+	return edges, width, counts
+}
+
+// scatterGridCells is how many cells per axis subsampleGridIndices divides
+// the plot into.
+const scatterGridCells = 32
+
+// subsampleGridIndices thins points to roughly max by dividing their
+// bounding box into a grid and keeping the same share of every cell, evenly
+// spread over the cell's points. Each occupied cell keeps at least one point
+// so sparse outliers survive, unless that alone would exceed max. It returns
+// the indices of the points kept, in ascending order, so values that go with
+// the points can be thinned alongside them. Every index is returned when
+// there are no more than max points or max is zero.
+func subsampleGridIndices(points [][2]float64, max int) []int {
+	if max <= 0 || len(points) <= max {
+		return countIndices(len(points))
+	}
+	minX, maxX, minY, maxY := points[0][0], points[0][0], points[0][1], points[0][1]
+	for _, p := range points {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+	cell := func(v, lo, hi float64) int {
+		if hi == lo {
+			return 0
+		}
+		c := int((v - lo) / (hi - lo) * scatterGridCells)
+		if c == scatterGridCells {
+			c--
+		}
+		return c
+	}
+	cells := map[int][]int{}
+	for i, p := range points {
+		k := cell(p[0], minX, maxX)*scatterGridCells + cell(p[1], minY, maxY)
+		cells[k] = append(cells[k], i)
+	}
+	keys := make([]int, 0, len(cells))
+	for k := range cells {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	share := float64(max) / float64(len(points))
+	out := make([]int, 0, max)
+	for _, k := range keys {
+		members := cells[k]
+		keep := int(math.Round(float64(len(members)) * share))
+		if keep < 1 {
+			keep = 1
+		}
+		for j := 0; j < keep; j++ {
+			out = append(out, members[j*len(members)/keep])
+		}
+	}
+	if len(out) > max {
+		thinned := make([]int, 0, max)
+		for j := 0; j < max; j++ {
+			thinned = append(thinned, out[j*len(out)/max])
+		}
+		out = thinned
+	}
+	sort.Ints(out)
+	return out
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLTTB(t *testing.T) {
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = math.Sin(float64(i) / 20)
+	}
+	for _, n := range []int{3, 10, 100, 999} {
+		kept := lttb(values, n)
+		if len(kept) != n {
+			t.Errorf("lttb(1000 values, %d) kept %d points", n, len(kept))
+			continue
+		}
+		if kept[0] != 0 || kept[n-1] != len(values)-1 {
+			t.Errorf("lttb(1000 values, %d) kept %d to %d, want the endpoints 0 and %d", n, kept[0], kept[n-1], len(values)-1)
+		}
+		for i := 1; i < n; i++ {
+			if kept[i] <= kept[i-1] {
+				t.Errorf("lttb(1000 values, %d) indices out of order at %d: %v", n, i, kept[i-1:i+1])
+				break
+			}
+		}
+	}
+	if kept := lttb(values[:5], 10); len(kept) != 5 {
+		t.Errorf("lttb(5 values, 10) kept %d points, want all 5", len(kept))
+	}
+}
+
+func TestDurationSeconds(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int64
+		want       float64
+	}{
+		{"seconds", 1700000000, 1700000012, 12},
+		{"milliseconds", 1700000000000, 1700000012500, 12.5},
+		{"zero start", 0, 1700000012, 0},
+		{"zero end", 1700000000000, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := durationSeconds(tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: durationSeconds(%d, %d) = %g, want %g", tt.name, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	delays := []float64{7, 1, 3, 9, 5}
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{"p0", delays, 0, 1},
+		{"p50", delays, 50, 5},
+		{"p100", delays, 100, 9},
+		{"interpolated", delays, 90, 8.2},
+		{"between ranks", []float64{10, 20}, 25, 12.5},
+		{"one value", []float64{4}, 99, 4},
+		{"no values", nil, 50, 0},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.values, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: percentile(%v, %g) = %g, want %g", tt.name, tt.values, tt.p, got, tt.want)
+		}
+	}
+	if delays[0] != 7 {
+		t.Errorf("percentile sorted its input in place: %v", delays)
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	values := []float64{2, 4, 6, 8}
+	tests := []struct {
+		name   string
+		window int
+		want   []float64
+	}{
+		{"window 2", 2, []float64{2, 3, 5, 7}},
+		{"window of all values", 4, []float64{2, 3, 4, 5}},
+		{"window longer than values", 10, []float64{2, 3, 4, 5}},
+		{"window 1", 1, values},
+		{"window 0", 0, values},
+	}
+	for _, tt := range tests {
+		if got := movingAverage(values, tt.window); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: movingAverage(%v, %d) = %v, want %v", tt.name, values, tt.window, got, tt.want)
+		}
+	}
+	if got := movingAverage(nil, 5); len(got) != 0 {
+		t.Errorf("movingAverage(nil, 5) = %v, want no values", got)
+	}
+}
+
+func TestThroughput(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      ContentMatrix
+		want   float64
+		wantOK bool
+	}{
+		{"seconds", ContentMatrix{Size: 10 << 20, DownloadStartedAt: 1700000000, DownloadFinishedAt: 1700000004}, 2.5, true},
+		{"milliseconds", ContentMatrix{Size: 3 << 20, DownloadStartedAt: 1700000000000, DownloadFinishedAt: 1700000000500}, 6, true},
+		{"zero duration", ContentMatrix{Size: 1 << 20, DownloadStartedAt: 1700000000, DownloadFinishedAt: 1700000000}, 0, false},
+		{"finished before started", ContentMatrix{Size: 1 << 20, DownloadStartedAt: 1700000010, DownloadFinishedAt: 1700000000}, 0, false},
+		{"never finished", ContentMatrix{Size: 1 << 20, DownloadStartedAt: 1700000000}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := throughput(tt.c)
+		if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: throughput = %g, %t, want %g, %t", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestProvidersOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		providedBy []string
+		want       []string
+	}{
+		{"nil", nil, []string{unknownProvider}},
+		{"empty", []string{}, []string{unknownProvider}},
+		{"blank ID", []string{""}, []string{unknownProvider}},
+		{"repeated", []string{"QmB", "QmA", "QmB"}, []string{"QmB", "QmA"}},
+	}
+	for _, tt := range tests {
+		if got := providersOf(ContentMatrix{ProvidedBy: tt.providedBy}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: providersOf(%q) = %q, want %q", tt.name, tt.providedBy, got, tt.want)
+		}
+	}
+}
+
+func TestMeanDownloadSpeedSkipsFailed(t *testing.T) {
+	data := &matrix{ContentMatrix: map[string]ContentMatrix{
+		"QmA":      {AvgSpeed: 2},
+		"QmB":      {AvgSpeed: 4},
+		"QmFailed": {AvgSpeed: 0},
+	}}
+	if got := meanDownloadSpeed(data); got != 3 {
+		t.Errorf("meanDownloadSpeed = %g, want 3 from the items that downloaded", got)
+	}
+	data.ContentMatrix = map[string]ContentMatrix{"QmFailed": {}}
+	if got := meanDownloadSpeed(data); got != 0 {
+		t.Errorf("meanDownloadSpeed = %g with every download failed, want 0", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// pageSummary rolls up the headline numbers of a matrix page for
+// summary.json. Delays are in seconds, speeds in MBps and the success rate
+// in percent.
+type pageSummary struct {
+	Page                  string  `json:"page"`
+	Nodes                 int     `json:"nodes"`
+	Contents              int     `json:"contents"`
+	MeanDiscoveryDelay    float64 `json:"meanDiscoveryDelay"`
+	MedianDiscoveryDelay  float64 `json:"medianDiscoveryDelay"`
+	MeanDownloadSpeed     float64 `json:"meanDownloadSpeed"`
+	TotalUptime           int64   `json:"totalUptime"`
+	ConnectionSuccessRate float64 `json:"connectionSuccessRate"`
+}
+
+func summarize(page string, data *matrix) pageSummary {
+	nodes := nodeIDs(data)
+	delays := discoveryDelays(data)
+	sum := 0.0
+	for _, d := range delays {
+		sum += d
+	}
+	s := pageSummary{
+		Page:                  page,
+		Nodes:                 len(nodes),
+		Contents:              len(data.ContentMatrix),
+		MedianDiscoveryDelay:  percentile(delays, 50),
+		MeanDownloadSpeed:     meanDownloadSpeed(data),
+		TotalUptime:           data.TotalUptime,
+		ConnectionSuccessRate: connectionSuccessRate(data),
+	}
+	if len(delays) > 0 {
+		s.MeanDiscoveryDelay = sum / float64(len(delays))
+	}
+	return s
+}
+
+// writeSummary writes the summaries of the rendered matrix pages to
+// summary.json in the output directory.
+func writeSummary(summaries []pageSummary) error {
+	if summaries == nil {
+		summaries = []pageSummary{}
+	}
+	b, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outPath("summary.json"), append(b, '\n'), 0644)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// fileStamp is what a log is checked against to tell it changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampOf stamps the log at path, or path.gz when that is what openLog
+// would read. A missing log has the zero stamp.
+func stampOf(path string) fileStamp {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		info, err = os.Stat(path + ".gz")
+	}
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{info.ModTime(), info.Size()}
+}
+
+// watchedPage is a page along with the logs it is rendered from.
+type watchedPage struct {
+	name    string
+	sources []string
+	render  func(string) error
+}
+
+func watchedPages() []watchedPage {
+	pages := make([]watchedPage, 0, len(matrixFiles)+len(batteryMeasurementFiles))
+	for _, v := range matrixFiles {
+		sources, ok := cfg.Merges[v]
+		if !ok {
+			sources = []string{logPath(v)}
+		}
+		render := func(name string) error {
+			_, err := renderMatrixPage(name)
+			return err
+		}
+		pages = append(pages, watchedPage{v, sources, render})
+	}
+	for _, v := range batteryMeasurementFiles {
+		pages = append(pages, watchedPage{v, []string{logPath(v)}, renderBatteryMeasurementPage})
+	}
+	return pages
+}
+
+// watch polls the logs of every page each interval and re-renders a page
+// when one of its logs changes, until ctx is cancelled. A log given by URL
+// can't be told to have changed without fetching it, so its pages are
+// re-rendered every interval. Render errors are
+// reported and watching carries on, so a log caught mid-write is picked up
+// again on its next change.
+func watch(ctx context.Context, interval time.Duration) {
+	pages := watchedPages()
+	stamps := map[string]fileStamp{}
+	for _, p := range pages {
+		for _, path := range p.sources {
+			stamps[path] = stampOf(path)
+		}
+	}
+	slog.Info("watching logs for changes", "pages", len(pages), "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed := map[string]bool{}
+		for path, old := range stamps {
+			if isURL(path) {
+				changed[path] = true
+				continue
+			}
+			if stamp := stampOf(path); stamp != old {
+				stamps[path] = stamp
+				changed[path] = true
+			}
+		}
+		for _, p := range pages {
+			if !anyChanged(p.sources, changed) {
+				continue
+			}
+			if err := p.render(p.name); err != nil {
+				reportError(fmt.Errorf("rendering %s: %w", p.name, err))
+				continue
+			}
+			if anyChanged(localLogs(p.sources), changed) {
+				slog.Info("log changed, re-rendered", "page", p.name)
+			} else {
+				slog.Debug("re-fetched remote logs, re-rendered", "page", p.name)
+			}
+		}
+	}
+}
+
+func anyChanged(paths []string, changed map[string]bool) bool {
+	for _, path := range paths {
+		if changed[path] {
+			return true
+		}
+	}
+	return false
+}
+
+// localLogs returns the paths that aren't URLs.
+func localLogs(paths []string) []string {
+	var local []string
+	for _, path := range paths {
+		if !isURL(path) {
+			local = append(local, path)
+		}
+	}
+	return local
+}